@@ -0,0 +1,166 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/howeyc/ledger/decimal"
+)
+
+// checkBalances sets the amount of each balance assignment, and checks each
+// balance assertion, using the running balance of the account with
+// transactions in date order. Returns an error for each assertion that does
+// not match, and each transaction with an assignment that does not balance.
+func (j *Journal) checkBalances(refs []postingRef) error {
+	asserted := make(map[*Account]postingRef)
+	for _, ref := range refs {
+		if ref.posting.Assertion != nil {
+			asserted[ref.posting] = ref
+		}
+	}
+	if len(asserted) == 0 {
+		return nil
+	}
+
+	sorted := slices.Clone(j.Transactions)
+	slices.SortStableFunc(sorted, func(a, b *Transaction) int {
+		return a.Date.Compare(b.Date)
+	})
+
+	balances := make(map[balanceKey]decimal.Decimal)
+
+	var errs []error
+	for _, trans := range sorted {
+		if aerr := assignBalances(trans, asserted, balances); aerr != nil {
+			errs = append(errs, aerr)
+		}
+
+		for i := range trans.AccountChanges {
+			posting := &trans.AccountChanges[i]
+			key := balanceKey{posting.Name, posting.Commodity}
+			sum, err := balances[key].CheckedAdd(posting.Balance)
+			if err != nil {
+				return errors.Join(append(errs, fmt.Errorf("balance of %s: %w", posting.Name, err))...)
+			}
+			balances[key] = sum
+
+			ref, found := asserted[posting]
+			if !found {
+				continue
+			}
+			assertion := posting.Assertion
+			bal := balances[balanceKey{posting.Name, assertion.Commodity}]
+			if bal.Cmp(assertion.Balance) != 0 {
+				errs = append(errs, ref.parseError(BalanceAssertionError, fmt.Errorf("balance assertion failed for %s: expected %s, got %s",
+					posting.Name,
+					amountString(assertion.Balance, assertion.Commodity),
+					amountString(bal, assertion.Commodity))))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// assignBalances sets the amount of each balance assignment in trans to the
+// difference between the assigned balance and the running balance of the
+// account, then places any remaining balance in the empty posting.
+func assignBalances(trans *Transaction, asserted map[*Account]postingRef, balances map[balanceKey]decimal.Decimal) error {
+	// only balance assignments to real postings need the transaction balanced
+	var assigned *postingRef
+	var hasAssignment bool
+	for i := range trans.AccountChanges {
+		if ref, found := asserted[&trans.AccountChanges[i]]; found && ref.assignment {
+			hasAssignment = true
+			if trans.AccountChanges[i].Kind == RealPosting {
+				assigned = &ref
+				break
+			}
+		}
+	}
+	if !hasAssignment {
+		return nil
+	}
+
+	// postings earlier in the transaction count towards the balance
+	pending := make(map[balanceKey]decimal.Decimal)
+	var commBals []commodityBalance
+	emptyAccIndex := -1
+	for i := range trans.AccountChanges {
+		posting := &trans.AccountChanges[i]
+		if ref, found := asserted[posting]; found && ref.assignment {
+			key := balanceKey{posting.Name, posting.Assertion.Commodity}
+			posting.Commodity = key.commodity
+			bal, err := balances[key].CheckedAdd(pending[key])
+			if err == nil {
+				posting.Balance, err = posting.Assertion.Balance.CheckedSub(bal)
+			}
+			if err != nil {
+				return ref.parseError(BalanceAssertionError, fmt.Errorf("balance of %s: %w", posting.Name, err))
+			}
+		} else if posting.Kind == RealPosting && posting.Balance.IsZero() {
+			emptyAccIndex = i
+			continue
+		}
+
+		key := balanceKey{posting.Name, posting.Commodity}
+		bal, err := pending[key].CheckedAdd(posting.Balance)
+		if err != nil {
+			return fmt.Errorf("balance of %s: %w", posting.Name, err)
+		}
+		pending[key] = bal
+		if posting.Kind != RealPosting {
+			continue
+		}
+		if posting.Cost != nil {
+			commBals, err = addBalance(commBals, posting.Cost.Commodity, posting.Cost.Total)
+		} else {
+			commBals, err = addBalance(commBals, posting.Commodity, posting.Balance)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if assigned == nil {
+		return nil
+	}
+
+	var unbalanced []commodityBalance
+	for _, cb := range commBals {
+		if !cb.balance.IsZero() {
+			unbalanced = append(unbalanced, cb)
+		}
+	}
+	if len(unbalanced) == 0 {
+		return nil
+	}
+	if emptyAccIndex < 0 {
+		return assigned.parseError(BalanceAssertionError, errors.New("unable to balance transaction: no empty account to place extra balance"))
+	}
+
+	// Each unbalanced commodity after the first gets an additional posting
+	// to the same account, which needs a new slice of postings.
+	if len(unbalanced) > 1 {
+		postings := make([]Account, len(trans.AccountChanges), len(trans.AccountChanges)+len(unbalanced)-1)
+		copy(postings, trans.AccountChanges)
+		for i := range trans.AccountChanges {
+			if ref, found := asserted[&trans.AccountChanges[i]]; found {
+				delete(asserted, &trans.AccountChanges[i])
+				asserted[&postings[i]] = ref
+			}
+		}
+		trans.AccountChanges = postings
+	}
+
+	emptyPosting := trans.AccountChanges[emptyAccIndex]
+	for i, cb := range unbalanced {
+		pIdx := emptyAccIndex
+		if i > 0 {
+			pIdx = len(trans.AccountChanges)
+			trans.AccountChanges = append(trans.AccountChanges, emptyPosting)
+		}
+		trans.AccountChanges[pIdx].Balance = cb.balance.Neg()
+		trans.AccountChanges[pIdx].Commodity = cb.commodity
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/howeyc/ledger/decimal"
+)
+
+// AutomatedTransaction adds its postings to transactions. For every posting
+// to an account that matches Expression, a regular expression, each of the
+// Postings is added to the transaction.
+type AutomatedTransaction struct {
+	Expression string
+	Postings   []AutomatedPosting
+	Comments   []string
+
+	match *regexp.Regexp
+	// source is the expression and lines as written, to tell if two
+	// automated transactions are the same
+	source string
+}
+
+// AutomatedPosting is a posting added by an automated transaction. When
+// Multiplier is set, the amount is the Balance multiplied by the amount of
+// the matched posting, in the commodity of the matched posting. Otherwise the
+// amount is the Balance in Commodity. The added postings are part of the
+// balance of the transaction.
+type AutomatedPosting struct {
+	Account
+	Multiplier bool
+}
+
+// parseAutomated parses an automated transaction, which is an account
+// expression (/regex/) followed by postings with either an amount or a
+// multiplier (*0.10).
+func (lp *parser) parseAutomated(expr string) (auto *AutomatedTransaction, err error) {
+	auto = &AutomatedTransaction{Expression: strings.TrimSpace(expr)}
+
+	pattern := auto.Expression
+	if len(pattern) > 1 && pattern[0] == '/' && pattern[len(pattern)-1] == '/' {
+		pattern = pattern[1 : len(pattern)-1]
+	}
+	if auto.match, err = regexp.Compile(pattern); err != nil {
+		return nil, fmt.Errorf("unable to parse account expression(%s): %w", auto.Expression, err)
+	}
+
+	var source strings.Builder
+	source.WriteString(auto.Expression)
+	for lp.scanner.Scan() {
+		line := lp.scanner.Text()
+		source.WriteString("\n" + line)
+
+		var comment string
+		if commentIdx := strings.Index(line, ";"); commentIdx >= 0 {
+			comment = line[commentIdx:]
+			line = line[:commentIdx]
+		}
+
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			if len(comment) == 0 {
+				break
+			}
+			auto.Comments = append(auto.Comments, comment)
+			continue
+		}
+
+		// the error is at the line of the posting, the rest of the postings
+		// are skipped by the caller
+		posting, err := parseAutomatedPosting(lp.options.numberFormat, line)
+		if err != nil {
+			return nil, err
+		}
+		posting.Comment = comment
+		auto.Postings = append(auto.Postings, posting)
+	}
+
+	if len(auto.Postings) == 0 {
+		return nil, errors.New("automated transaction requires postings")
+	}
+	auto.source = source.String()
+	return auto, nil
+}
+
+// parseAutomatedPosting parses a posting of an automated transaction. The
+// account must be separated from the amount by a tab or at least two spaces.
+func parseAutomatedPosting(f decimal.NumberFormat, line string) (posting AutomatedPosting, err error) {
+	if posting.Kind, line, err = splitPostingKind(line); err != nil {
+		return posting, err
+	}
+	name, amount, found := strings.Cut(line, "\t")
+	if iSep := strings.Index(line, "  "); iSep >= 0 && (!found || iSep < len(name)) {
+		name, amount, found = line[:iSep], line[iSep:], true
+	}
+	if !found {
+		return posting, fmt.Errorf("automated posting requires an amount: %s", line)
+	}
+
+	posting.Name = strings.TrimSpace(name)
+	amount = strings.TrimSpace(amount)
+	if multiplier, isMultiplier := strings.CutPrefix(amount, "*"); isMultiplier {
+		posting.Multiplier = true
+		posting.Balance, err = f.Parse(strings.TrimSpace(multiplier))
+	} else {
+		posting.Balance, posting.Commodity, err = parseCommodityAmount(f, amount)
+	}
+	if err != nil {
+		return posting, fmt.Errorf("unable to parse automated posting amount(%s): %w", amount, err)
+	}
+	return posting, nil
+}
+
+// applyAutomated adds the postings of the automated transactions for the
+// numPostings postings of the transaction being parsed, before it is
+// balanced. Returns the new number of postings.
+//
+// A multiplier needs the amount of the matched posting, so it can not apply
+// to a posting without an amount, which is only known once balanced.
+func (lp *parser) applyAutomated(numPostings int) (int, error) {
+	accIndex := numPostings
+	for _, auto := range lp.autos {
+		for i := range numPostings {
+			matched := lp.postings[lp.cpIdx+i]
+			if !auto.match.MatchString(matched.Name) {
+				continue
+			}
+			for _, autoPosting := range auto.Postings {
+				lp.reservePosting(accIndex)
+				posting := &lp.postings[lp.cpIdx+accIndex]
+				*posting = autoPosting.Account
+				if autoPosting.Multiplier {
+					if matched.Balance.IsZero() {
+						return accIndex, fmt.Errorf("unable to apply automated transaction(%s): %s has no amount to multiply", auto.Expression, matched.Name)
+					}
+					balance, err := matched.Balance.CheckedMul(autoPosting.Balance)
+					if err != nil {
+						return accIndex, fmt.Errorf("unable to multiply %s: %w", matched.Name, err)
+					}
+					posting.Balance = balance
+					posting.Commodity = matched.Commodity
+				}
+				// added postings are never the empty posting that is
+				// balanced
+				var err error
+				switch posting.Kind {
+				case RealPosting:
+					lp.realBals.commBals, err = addBalance(lp.realBals.commBals, posting.Commodity, posting.Balance)
+				case BalancedVirtualPosting:
+					lp.virtualBals.commBals, err = addBalance(lp.virtualBals.commBals, posting.Commodity, posting.Balance)
+				}
+				if err != nil {
+					return accIndex, err
+				}
+				accIndex++
+			}
+		}
+	}
+	return accIndex, nil
+}
@@ -1,47 +1,65 @@
 package ledger
 
 import (
+	"cmp"
+	"fmt"
 	"slices"
 	"strings"
 
 	"github.com/howeyc/ledger/decimal"
 )
 
+// balanceKey identifies the balance of a single commodity in an account.
+type balanceKey struct {
+	name, commodity string
+}
+
 // GetBalances provided a list of transactions and filter strings, returns account balances of
 // all accounts that have any filter as a substring of the account name. Also
 // returns balances for each account level depth as a separate record.
 //
-// Accounts are sorted by name.
-func GetBalances(generalLedger []*Transaction, filterArr []string) []*Account {
+// Accounts holding more than one commodity have a separate record for each
+// commodity.
+//
+// Accounts are sorted by name, then by commodity. Returns an error if a
+// balance does not fit in a decimal.Decimal.
+func GetBalances(generalLedger []*Transaction, filterArr []string) ([]*Account, error) {
 	var accList []*Account
-	balances := make(map[string]*Account)
+	balances := make(map[balanceKey]*Account)
 
 	// at every depth, for each account, track the parent account
-	depthMap := make(map[int]map[string]string)
+	depthMap := make(map[int]map[balanceKey]string)
 	var maxDepth int
 
-	incAccount := func(accName string, val decimal.Decimal) {
+	incAccount := func(accName, commodity string, val decimal.Decimal) error {
+		key := balanceKey{name: accName, commodity: commodity}
+
 		// track parent
 		accDepth := strings.Count(accName, ":") + 1
 		pmap, pmapfound := depthMap[accDepth]
 		if !pmapfound {
-			pmap = make(map[string]string)
+			pmap = make(map[balanceKey]string)
 			depthMap[accDepth] = pmap
 		}
-		if _, foundparent := pmap[accName]; !foundparent && accDepth > 1 {
+		if _, foundparent := pmap[key]; !foundparent && accDepth > 1 {
 			colIdx := strings.LastIndex(accName, ":")
-			pmap[accName] = accName[:colIdx]
+			pmap[key] = accName[:colIdx]
 			maxDepth = max(maxDepth, accDepth)
 		}
 
 		// add to balance
-		if acc, ok := balances[accName]; !ok {
-			acc := &Account{Name: accName, Balance: val}
+		if acc, ok := balances[key]; !ok {
+			acc := &Account{Name: accName, Balance: val, Commodity: commodity}
 			accList = append(accList, acc)
-			balances[accName] = acc
+			balances[key] = acc
 		} else {
-			acc.Balance = acc.Balance.Add(val)
+			sum, err := acc.Balance.CheckedAdd(val)
+			if err != nil {
+				return fmt.Errorf("balance of %s: %w", accName, err)
+			}
+			acc.Balance = sum
 		}
+		return nil
 	}
 
 	for _, trans := range generalLedger {
@@ -53,20 +71,132 @@ func GetBalances(generalLedger []*Transaction, filterArr []string) []*Account {
 				}
 			}
 			if inFilter {
-				incAccount(accChange.Name, accChange.Balance)
+				if err := incAccount(accChange.Name, accChange.Commodity, accChange.Balance); err != nil {
+					return nil, err
+				}
 			}
 		}
 	}
 
 	// roll-up balances
 	for curDepth := maxDepth; curDepth > 1; curDepth-- {
-		for accName, parentName := range depthMap[curDepth] {
-			incAccount(parentName, balances[accName].Balance)
+		for key, parentName := range depthMap[curDepth] {
+			if err := incAccount(parentName, key.commodity, balances[key].Balance); err != nil {
+				return nil, err
+			}
 		}
 	}
 
 	slices.SortFunc(accList, func(a, b *Account) int {
-		return strings.Compare(a.Name, b.Name)
+		return cmp.Or(
+			strings.Compare(a.Name, b.Name),
+			strings.Compare(a.Commodity, b.Commodity),
+		)
+	})
+	return accList, nil
+}
+
+// costHolding is the quantity held of a commodity, along with its cost in
+// each cost commodity.
+type costHolding struct {
+	quantity decimal.Decimal
+	costs    map[string]decimal.Decimal
+}
+
+// add adds quantity of the commodity, at cost in commodity.
+func (h *costHolding) add(quantity decimal.Decimal, commodity string, cost decimal.Decimal) (err error) {
+	if h.quantity, err = h.quantity.CheckedAdd(quantity); err != nil {
+		return err
+	}
+	h.costs[commodity], err = h.costs[commodity].CheckedAdd(cost)
+	return err
+}
+
+// reduce removes quantity (a positive amount) of the commodity at the
+// average cost of the quantity held.
+func (h *costHolding) reduce(quantity decimal.Decimal) error {
+	if quantity.Cmp(h.quantity) >= 0 {
+		h.quantity = decimal.Zero
+		clear(h.costs)
+		return nil
+	}
+	for commodity, cost := range h.costs {
+		removed, err := cost.CheckedMul(quantity)
+		if err != nil {
+			return err
+		}
+		if removed, err = removed.DivRound(h.quantity, decimal.RoundHalfEven); err != nil {
+			return err
+		}
+		h.costs[commodity] = cost.Sub(removed)
+	}
+	h.quantity = h.quantity.Sub(quantity)
+	return nil
+}
+
+// GetCostBasis returns the cost basis of all postings to the account named
+// accountName, with a separate record for each cost commodity.
+//
+// Postings with a lot cost ({}) count at their lot cost, postings with only a
+// price (@, @@) count at their price, and postings without a commodity count
+// at their amount. Postings that reduce a commodity without a lot cost, such
+// as a sale at a price, reduce the cost basis at the average cost of the
+// commodity held. Postings that add a commodity without a price or lot cost
+// have no known cost and are skipped.
+//
+// Records are sorted by commodity.
+func GetCostBasis(generalLedger []*Transaction, accountName string) ([]*Account, error) {
+	holdings := make(map[string]*costHolding)
+	for _, trans := range generalLedger {
+		for _, accChange := range trans.AccountChanges {
+			if accChange.Name != accountName {
+				continue
+			}
+			holding, found := holdings[accChange.Commodity]
+			if !found {
+				holding = &costHolding{costs: make(map[string]decimal.Decimal)}
+				holdings[accChange.Commodity] = holding
+			}
+			var err error
+			switch {
+			case len(accChange.Commodity) == 0:
+				err = holding.add(accChange.Balance, "", accChange.Balance)
+			case accChange.Cost != nil && accChange.Cost.HasLot():
+				var lotCost decimal.Decimal
+				if lotCost, err = accChange.Cost.LotPrice.CheckedMul(accChange.Balance); err == nil {
+					err = holding.add(accChange.Balance, accChange.Cost.LotCommodity, lotCost)
+				}
+			case accChange.Balance.Sign() < 0:
+				err = holding.reduce(accChange.Balance.Neg())
+			case accChange.Cost != nil:
+				err = holding.add(accChange.Balance, accChange.Cost.Commodity, accChange.Cost.Total)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("cost of %s: %w", accountName, err)
+			}
+		}
+	}
+
+	costs := make(map[string]*Account)
+	var costList []*Account
+	for _, holding := range holdings {
+		for commodity, cost := range holding.costs {
+			acc, found := costs[commodity]
+			if !found {
+				acc = &Account{Name: accountName, Commodity: commodity}
+				costs[commodity] = acc
+				costList = append(costList, acc)
+			}
+			sum, err := acc.Balance.CheckedAdd(cost)
+			if err != nil {
+				return nil, fmt.Errorf("cost of %s: %w", accountName, err)
+			}
+			acc.Balance = sum
+		}
+	}
+
+	slices.SortFunc(costList, func(a, b *Account) int {
+		return strings.Compare(a.Commodity, b.Commodity)
 	})
-	return accList
+	return costList, nil
 }
@@ -3,8 +3,10 @@ package ledger
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
+	"slices"
 	"testing"
 	"time"
 
@@ -71,13 +73,63 @@ var testBalCases = []testBalCase{
 		},
 		nil,
 	},
+	{
+		"commodities",
+		`1970/01/01 Payee
+	Assets:Broker  10 AAPL
+	Assets:Cash    $-1500
+	Equity
+
+1970/01/01 Payee
+	Assets:Cash    $200
+	Income
+`,
+		[]Account{
+			{
+				Name:      "Assets",
+				Balance:   decimal.NewFromFloat(-1300),
+				Commodity: "$",
+			},
+			{
+				Name:      "Assets",
+				Balance:   decimal.NewFromFloat(10),
+				Commodity: "AAPL",
+			},
+			{
+				Name:      "Assets:Broker",
+				Balance:   decimal.NewFromFloat(10),
+				Commodity: "AAPL",
+			},
+			{
+				Name:      "Assets:Cash",
+				Balance:   decimal.NewFromFloat(-1300),
+				Commodity: "$",
+			},
+			{
+				Name:      "Equity",
+				Balance:   decimal.NewFromFloat(1500),
+				Commodity: "$",
+			},
+			{
+				Name:      "Equity",
+				Balance:   decimal.NewFromFloat(-10),
+				Commodity: "AAPL",
+			},
+			{
+				Name:      "Income",
+				Balance:   decimal.NewFromFloat(-200),
+				Commodity: "$",
+			},
+		},
+		nil,
+	},
 }
 
 func TestBalanceLedger(t *testing.T) {
 	for _, tc := range testBalCases {
 		b := bytes.NewBufferString(tc.data)
 		transactions, err := ParseLedger(b)
-		bals := GetBalances(transactions, []string{})
+		bals, _ := GetBalances(transactions, []string{})
 		if (err != nil && tc.err == nil) || (err != nil && tc.err != nil && err.Error() != tc.err.Error()) {
 			t.Errorf("Error: expected `%s`, got `%s`", tc.err, err)
 		}
@@ -89,6 +141,166 @@ func TestBalanceLedger(t *testing.T) {
 	}
 }
 
+func TestGetCostBasis(t *testing.T) {
+	b := bytes.NewBufferString(`
+2024/01/01 Buy
+	Assets:Broker  10 AAPL @ $150
+	Assets:Cash
+
+2024/01/02 Buy
+	Assets:Broker  2 AAPL {$160}
+	Assets:Cash
+
+2024/02/01 Sell
+	Assets:Broker  -5 AAPL {$150} @ $180
+	Assets:Cash
+	Income:Gains   $-150
+
+2024/02/02 Gift
+	Assets:Broker  1 AAPL
+	Equity
+`)
+
+	trans, err := ParseLedger(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	costs, err := GetCostBasis(trans, "Assets:Broker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(costs) != 1 || costs[0].Commodity != "$" || costs[0].Balance.Cmp(decimal.NewFromInt(1070)) != 0 {
+		t.Errorf("cost basis not accurate: %+v", costs)
+	}
+}
+
+func TestGetCostBasisAverageCost(t *testing.T) {
+	b := bytes.NewBufferString(`
+2024/01/01 Buy
+	Assets:Broker  10 AAPL @ $100
+	Assets:Cash
+
+2024/01/02 Buy
+	Assets:Broker  10 AAPL @ $200
+	Assets:Cash
+
+2024/01/03 Buy
+	Assets:Broker  4 SAP @ 50 EUR
+	Assets:Cash
+
+2024/02/01 Sell
+	Assets:Broker  -5 AAPL @ $300
+	Assets:Cash
+
+2024/02/02 Transfer
+	Assets:Broker  -1 SAP
+	Assets:Other
+`)
+
+	trans, err := ParseLedger(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	costs, err := GetCostBasis(trans, "Assets:Broker")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, cost := range costs {
+		got = append(got, cost.Commodity+cost.Balance.StringFixedBank())
+	}
+	if exp := []string{"$2250.00", "EUR150.00"}; !slices.Equal(got, exp) {
+		t.Errorf("cost basis: expected %v, got %v", exp, got)
+	}
+}
+
+func TestMarketBalances(t *testing.T) {
+	b := bytes.NewBufferString(`
+P 2024/01/01 AAPL $150
+P 2024/02/01 AAPL $180
+P 2024/01/01 EUR $1.25
+
+2024/01/05 Buy
+	Assets:Broker  10 AAPL @ $150
+	Assets:Cash
+
+2024/01/06 Travel
+	Assets:Cash  100 EUR
+	Income
+`)
+
+	journal, err := ParseJournal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	balances, err := GetBalances(journal.Transactions, []string{"Assets"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		target string
+		date   time.Time
+		assets string
+	}{
+		{"$", time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC), "$125.00"},
+		{"$", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), "$425.00"},
+		{"", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), "$425.00"},
+		{"EUR", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), "EUR340.00"},
+	} {
+		mbals, err := journal.Prices.MarketBalances(balances, tc.target, tc.date)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := mbals[0].Commodity + mbals[0].Balance.StringFixedBank(); mbals[0].Name != "Assets" || got != tc.assets {
+			t.Errorf("market balance(%s, %s): expected %s, got %s", tc.target, tc.date.Format(time.DateOnly), tc.assets, got)
+		}
+	}
+
+	rbs, err := MarketBalancesByPeriod(journal.Transactions, PeriodMonth, RangeSnapshot, journal.Prices, "$")
+	if err != nil || len(rbs) != 1 || rbs[0].Balances[0].Balance.Cmp(decimal.NewFromInt(125)) != 0 {
+		t.Error("market balances by period not accurate")
+	}
+}
+
+func TestMarketBalancesSameDayPrices(t *testing.T) {
+	journal, err := ParseJournal(bytes.NewBufferString(`
+P 2024/01/01 AAPL $1
+P 2024/01/01 AAPL 2 EUR
+P 2024/01/01 AAPL $3
+
+2024/01/05 Buy
+	Assets:Broker  10 AAPL
+	Equity
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prices := journal.Prices.Prices("AAPL"); len(prices) != 2 {
+		t.Fatalf("expected 2 prices, got %v", prices)
+	}
+	balances, err := GetBalances(journal.Transactions, []string{"Assets"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		target string
+		assets string
+	}{
+		{"$", "$30.00"},
+		{"EUR", "EUR20.00"},
+	} {
+		mbals, err := journal.Prices.MarketBalances(balances, tc.target, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := mbals[0].Commodity + mbals[0].Balance.StringFixedBank(); got != tc.assets {
+			t.Errorf("market balance(%s): expected %s, got %s", tc.target, tc.assets, got)
+		}
+	}
+}
+
 func BenchmarkGetBalances(b *testing.B) {
 	trans := make([]*Transaction, 0, 100000)
 	for i := range 100000 {
@@ -144,8 +356,8 @@ func TestBalancesByPeriod(t *testing.T) {
 `)
 
 	trans, _ := ParseLedger(b)
-	partitionRb := BalancesByPeriod(trans, PeriodQuarter, RangePartition)
-	snapshotRb := BalancesByPeriod(trans, PeriodQuarter, RangeSnapshot)
+	partitionRb, _ := BalancesByPeriod(trans, PeriodQuarter, RangePartition)
+	snapshotRb, _ := BalancesByPeriod(trans, PeriodQuarter, RangeSnapshot)
 
 	if partitionRb[len(partitionRb)-1].Balances[0].Balance.Abs().Cmp(decimal.NewFromInt(100)) != 0 {
 		t.Error("range balance by partition not accurate")
@@ -155,14 +367,38 @@ func TestBalancesByPeriod(t *testing.T) {
 	}
 
 	transPeriod := TransactionsByPeriod(trans, PeriodQuarter)
-	lastBals := GetBalances(transPeriod[len(transPeriod)-1].Transactions, []string{})
+	lastBals, _ := GetBalances(transPeriod[len(transPeriod)-1].Transactions, []string{})
 	if partitionRb[len(partitionRb)-1].Balances[0].Balance.Abs().Cmp(lastBals[0].Balance.Abs()) != 0 {
 		t.Error("range balance by partition not equal to trans by period balance")
 	}
 
 	var blanktrans []*Transaction
-	rb := BalancesByPeriod(blanktrans, PeriodDay, RangeSnapshot)
+	rb, _ := BalancesByPeriod(blanktrans, PeriodDay, RangeSnapshot)
 	if len(rb) > 1 {
 		t.Error("range balances for non-existent transactions")
 	}
 }
+
+func TestBalancesOverflow(t *testing.T) {
+	big := decimal.NewFromInt(9e15)
+	trans := []*Transaction{
+		{AccountChanges: []Account{{Name: "Assets:Cash", Balance: big}}},
+		{AccountChanges: []Account{{Name: "Assets:Cash", Balance: big}}},
+	}
+	if _, err := GetBalances(trans, []string{}); !errors.Is(err, decimal.ErrOverflow) {
+		t.Errorf("expected overflow, got `%v`", err)
+	}
+	if _, err := GetCostBasis(trans, "Assets:Cash"); !errors.Is(err, decimal.ErrOverflow) {
+		t.Errorf("expected overflow in cost basis, got `%v`", err)
+	}
+	balances := []*Account{{Name: "Assets:Cash", Balance: big}, {Name: "Assets:Cash", Balance: big}}
+	if _, err := (PriceHistory{}).MarketBalances(balances, "$", time.Now()); !errors.Is(err, decimal.ErrOverflow) {
+		t.Errorf("expected overflow in market balances, got `%v`", err)
+	}
+
+	// the sum of postings checked when balancing is also checked
+	_, err := ParseLedger(bytes.NewBufferString("2024/01/01 Big\n\tA    5000000000000000\n\tB    5000000000000000\n\tC\n"))
+	if !errors.Is(err, decimal.ErrOverflow) {
+		t.Errorf("expected overflow when balancing, got `%v`", err)
+	}
+}
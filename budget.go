@@ -0,0 +1,133 @@
+package ledger
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/howeyc/ledger/decimal"
+)
+
+// BudgetAccount holds the budgeted and actual amounts, in Commodity, of an
+// account. The actual amount includes postings to sub-accounts.
+type BudgetAccount struct {
+	Name      string
+	Commodity string
+	Budget    decimal.Decimal
+	Actual    decimal.Decimal
+}
+
+// Remaining returns the budget that has not been used by the actual amount.
+func (b *BudgetAccount) Remaining() (decimal.Decimal, error) {
+	return b.Budget.CheckedSub(b.Actual)
+}
+
+// PercentUsed returns the actual amount as a percentage of the budget, or
+// zero for an account without a budget.
+func (b *BudgetAccount) PercentUsed() (decimal.Decimal, error) {
+	if b.Budget.IsZero() {
+		return decimal.Zero, nil
+	}
+	percent, err := b.Actual.CheckedMul(decimal.NewFromInt(100))
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return percent.CheckedDiv(b.Budget)
+}
+
+// GetBudget returns the budget accounts, sorted by name and commodity, for
+// the transactions between start and end (not including end). The budget is
+// the sum of the postings generated by the periodic transactions over the
+// same dates, see Forecast.
+func GetBudget(trans []*Transaction, periodic []*PeriodicTransaction, start, end time.Time) ([]*BudgetAccount, error) {
+	var budgetList []*BudgetAccount
+	budgets := make(map[balanceKey]*BudgetAccount)
+	for _, ftrans := range Forecast(periodic, start, end) {
+		for _, accChange := range ftrans.AccountChanges {
+			key := balanceKey{accChange.Name, accChange.Commodity}
+			budget, found := budgets[key]
+			if !found {
+				budget = &BudgetAccount{Name: key.name, Commodity: key.commodity}
+				budgets[key] = budget
+				budgetList = append(budgetList, budget)
+			}
+			sum, err := budget.Budget.CheckedAdd(accChange.Balance)
+			if err != nil {
+				return nil, fmt.Errorf("budget of %s: %w", key.name, err)
+			}
+			budget.Budget = sum
+		}
+	}
+
+	for _, tran := range TransactionsInDateRange(trans, start, end) {
+		for _, accChange := range tran.AccountChanges {
+			// count towards the account and every parent with a budget
+			name := accChange.Name
+			for {
+				if budget, found := budgets[balanceKey{name, accChange.Commodity}]; found {
+					sum, err := budget.Actual.CheckedAdd(accChange.Balance)
+					if err != nil {
+						return nil, fmt.Errorf("actual of %s: %w", name, err)
+					}
+					budget.Actual = sum
+				}
+				iSep := strings.LastIndexByte(name, ':')
+				if iSep < 0 {
+					break
+				}
+				name = name[:iSep]
+			}
+		}
+	}
+
+	slices.SortFunc(budgetList, func(a, b *BudgetAccount) int {
+		return cmp.Or(
+			strings.Compare(a.Name, b.Name),
+			strings.Compare(a.Commodity, b.Commodity),
+		)
+	})
+	return budgetList, nil
+}
+
+// RangeBudget contains the budget accounts and the start and end time of the
+// date range
+type RangeBudget struct {
+	Start, End time.Time
+	Accounts   []*BudgetAccount
+}
+
+// BudgetByPeriod will return the budget accounts for each period. The
+// periods start no later than the first repeat of the periodic transactions,
+// so a budget that starts before the first transaction is not dropped.
+func BudgetByPeriod(trans []*Transaction, periodic []*PeriodicTransaction, per Period) ([]*RangeBudget, error) {
+	tStart, tEnd := startEndTime(trans)
+	if len(trans) > 0 {
+		for _, pt := range periodic {
+			first := pt.Start
+			if first.IsZero() {
+				first = getDateBoundaries(pt.Period, tStart, tEnd)[0]
+			}
+			if first.Before(tStart) {
+				tStart = first
+			}
+		}
+	}
+
+	boundaries := getDateBoundaries(per, tStart, tEnd)
+	results := make([]*RangeBudget, 0, len(boundaries)-1)
+
+	bStart := boundaries[0]
+	for _, bEnd := range boundaries[1:] {
+		budgets, err := GetBudget(TransactionsInDateRange(trans, bStart, bEnd), periodic, bStart, bEnd)
+		if err != nil {
+			return nil, err
+		}
+		// End date should be the last day (inclusive, so subtract 1 day)
+		results = append(results, &RangeBudget{Start: bStart, End: bEnd.AddDate(0, 0, -1), Accounts: budgets})
+
+		bStart = bEnd
+	}
+	return results, nil
+}
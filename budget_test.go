@@ -0,0 +1,109 @@
+package ledger
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestBudgetByPeriod(t *testing.T) {
+	journal, err := ParseJournal(bytes.NewBufferString(`~ Monthly from 2024/01/01  Budget
+	Expenses:Food      400
+	Assets:Checking
+
+2024/01/03 Store
+	Expenses:Food:Grocery  150
+	Assets:Checking
+
+2024/02/03 Store
+	Expenses:Food          450
+	Assets:Checking
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rbudgets, err := BudgetByPeriod(journal.Transactions, journal.Periodic, PeriodMonth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rbudgets) != 2 {
+		t.Fatalf("Error: expected `2` periods, got `%d`", len(rbudgets))
+	}
+	if !rbudgets[1].Start.Equal(time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Error: expected second period to start 2024-02-01, got %s", rbudgets[1].Start.Format(time.DateOnly))
+	}
+
+	expected := [][]string{
+		{"Assets:Checking", "-400.00", "-150.00", "-250.00", "38"},
+		{"Expenses:Food", "400.00", "150.00", "250.00", "38"},
+		{"Assets:Checking", "-400.00", "-450.00", "50.00", "113"},
+		{"Expenses:Food", "400.00", "450.00", "-50.00", "113"},
+	}
+	var got [][]string
+	for _, rb := range rbudgets {
+		for _, budget := range rb.Accounts {
+			remaining, rerr := budget.Remaining()
+			percent, perr := budget.PercentUsed()
+			if rerr != nil || perr != nil {
+				t.Fatal(rerr, perr)
+			}
+			got = append(got, []string{budget.Name, budget.Budget.StringFixedBank(), budget.Actual.StringFixedBank(),
+				remaining.StringFixedBank(), percent.StringRound()})
+		}
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Error: expected `%v`, got `%v`", expected, got)
+	}
+	for i := range expected {
+		for j := range expected[i] {
+			if expected[i][j] != got[i][j] {
+				t.Errorf("Error: expected `%v`, got `%v`", expected[i], got[i])
+				break
+			}
+		}
+	}
+}
+
+func TestBudgetByPeriodBeforeFirstTransaction(t *testing.T) {
+	journal, err := ParseJournal(bytes.NewBufferString(`~ Monthly from 2024/01/01  Budget
+	Expenses:Food      400
+	Assets:Checking
+
+2024/01/03 Store
+	Expenses:Food  150
+	Assets:Checking
+
+2024/02/03 Store
+	Expenses:Food  450
+	Assets:Checking
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rbudgets, err := BudgetByPeriod(journal.Transactions, journal.Periodic, Period(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rbudgets) != 1 {
+		t.Fatalf("Error: expected `1` period, got `%d`", len(rbudgets))
+	}
+	if !rbudgets[0].Start.Equal(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Error: expected period to start 2024-01-01, got %s", rbudgets[0].Start.Format(time.DateOnly))
+	}
+	var found bool
+	for _, budget := range rbudgets[0].Accounts {
+		if budget.Name != "Expenses:Food" {
+			continue
+		}
+		found = true
+		if budget.Budget.StringFixedBank() != "800.00" || budget.Actual.StringFixedBank() != "600.00" {
+			t.Errorf("Error: expected budget `800.00` and actual `600.00`, got `%s` and `%s`",
+				budget.Budget.StringFixedBank(), budget.Actual.StringFixedBank())
+		}
+	}
+	if !found {
+		t.Error("Error: expected a budget for `Expenses:Food`")
+	}
+}
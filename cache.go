@@ -0,0 +1,239 @@
+package ledger
+
+import (
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// JournalCache holds the journal of a ledger file, which is only parsed again
+// once the file, or a file it includes, has changed. Only the files that
+// have changed are parsed again, the results of the others are kept, and
+// their include directives are followed again.
+//
+// A file has changed when its modification time or size is different, or an
+// include pattern in it matches different files. A file is also parsed again
+// when the automated transactions that apply to it are written differently.
+type JournalCache struct {
+	filename string
+	opts     []ParseOption
+
+	mu       sync.Mutex
+	includes includeCache
+	journal  *Journal
+	err      error
+	sources  sources
+}
+
+// NewJournalCache returns a cache of the journal of a ledger file, parsed
+// with the options.
+func NewJournalCache(filename string, opts ...ParseOption) *JournalCache {
+	return &JournalCache{filename: filename, opts: opts}
+}
+
+// Journal returns the journal of the ledger file, the same as
+// ParseJournalFile, parsing the files that have changed since the last call.
+// The same journal is returned until a file changes, so changes to it are
+// seen by later calls.
+func (c *JournalCache) Journal() (*Journal, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.journal != nil && !c.sources.changed() {
+		return c.journal, c.err
+	}
+
+	c.includes.used = make(map[string]*includeEntry)
+	c.includes.sources = sources{}
+	c.journal, c.err = ParseJournalFile(c.filename, slices.Concat(c.opts, []ParseOption{withIncludeCache(&c.includes)})...)
+	// drop files that are no longer included
+	c.includes.entries, c.includes.used = c.includes.used, nil
+	c.sources = c.includes.sources
+	return c.journal, c.err
+}
+
+// withIncludeCache returns an option that uses the results of included files
+// in the cache, and keeps the results of included files in the cache.
+func withIncludeCache(cache *includeCache) ParseOption {
+	return func(po *parseOptions) {
+		po.cache = cache
+	}
+}
+
+// includeCache holds the results of each included file, along with the
+// files it includes.
+type includeCache struct {
+	mu      sync.Mutex
+	entries map[string]*includeEntry
+	used    map[string]*includeEntry
+
+	// sources of the whole journal
+	sources sources
+}
+
+// includeEntry is the results of a file parsed with autos, without the
+// results of the files it includes.
+type includeEntry struct {
+	autos   []*AutomatedTransaction
+	results []includedResult
+	sources sources
+}
+
+// lookup returns a copy of the results of the file at path, if the file was
+// parsed with the same autos and has not changed since.
+func (c *includeCache) lookup(path string, autos []*AutomatedTransaction) ([]includedResult, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	entry := c.entries[path]
+	c.mu.Unlock()
+	if entry == nil || !equalAutos(entry.autos, autos) || entry.sources.changed() {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	c.used[path] = entry
+	c.mu.Unlock()
+	return cloneResults(entry.results), true
+}
+
+// store keeps a copy of the results of the file at path, before the journal
+// changes them.
+func (c *includeCache) store(path string, autos []*AutomatedTransaction, results []includedResult) {
+	if c == nil {
+		return
+	}
+	entry := &includeEntry{autos: autos, results: cloneResults(results)}
+	for _, ir := range results {
+		if ir.result != nil {
+			entry.sources.add(ir.result.sources)
+		}
+	}
+
+	c.mu.Lock()
+	c.used[path] = entry
+	c.mu.Unlock()
+}
+
+// equalAutos returns true if both lists have the same automated
+// transactions, as written.
+func equalAutos(a, b []*AutomatedTransaction) bool {
+	return slices.EqualFunc(a, b, func(x, y *AutomatedTransaction) bool {
+		return x.source == y.source
+	})
+}
+
+// cloneResults returns a copy of the results with copies of the
+// transactions and postings, which are changed by aliases and balance
+// assignments once parsed.
+func cloneResults(results []includedResult) []includedResult {
+	cloned := slices.Clone(results)
+	for i, ir := range results {
+		if ir.result == nil {
+			continue
+		}
+		r := *ir.result
+		postings := make(map[*Account]*Account)
+		r.transactions = make([]*Transaction, len(ir.result.transactions))
+		for j, trans := range ir.result.transactions {
+			r.transactions[j] = cloneTransaction(trans, postings)
+		}
+		r.periodic = make([]*PeriodicTransaction, len(ir.result.periodic))
+		for j, periodic := range ir.result.periodic {
+			p := *periodic
+			p.Transaction = *cloneTransaction(&periodic.Transaction, postings)
+			r.periodic[j] = &p
+		}
+		r.postingRefs = slices.Clone(ir.result.postingRefs)
+		for j := range r.postingRefs {
+			r.postingRefs[j].posting = postings[r.postingRefs[j].posting]
+		}
+		cloned[i].result = &r
+	}
+	return cloned
+}
+
+// cloneTransaction returns a copy of trans and its postings, adding each
+// posting and its copy to postings.
+func cloneTransaction(trans *Transaction, postings map[*Account]*Account) *Transaction {
+	clone := *trans
+	clone.AccountChanges = slices.Clone(trans.AccountChanges)
+	for i := range clone.AccountChanges {
+		posting := &clone.AccountChanges[i]
+		if posting.Cost != nil {
+			cost := *posting.Cost
+			posting.Cost = &cost
+		}
+		postings[&trans.AccountChanges[i]] = posting
+	}
+	return &clone
+}
+
+// sources are the files read by the parser, and the files matched by include
+// patterns, to tell when parsing again would give different results.
+type sources struct {
+	files    []fileStamp
+	includes []includeMatch
+}
+
+// fileStamp is the modification time and size of a file when it was read.
+type fileStamp struct {
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// includeMatch is the paths matched by the pattern of an include directive
+// in a file.
+type includeMatch struct {
+	filename string
+	pattern  string
+	paths    []string
+}
+
+// addFile adds the file at path as it is now. Files that cannot be read are
+// added with a zero time and size.
+func (s *sources) addFile(path string) {
+	stamp := fileStamp{path: path}
+	if fi, err := os.Stat(path); err == nil {
+		stamp.modTime = fi.ModTime()
+		stamp.size = fi.Size()
+	}
+	s.files = append(s.files, stamp)
+}
+
+// addInclude adds the paths matched by the pattern of an include directive
+// in the file filename.
+func (s *sources) addInclude(filename, pattern string, paths []string) {
+	s.includes = append(s.includes, includeMatch{filename: filename, pattern: pattern, paths: paths})
+}
+
+// add adds other sources.
+func (s *sources) add(other sources) {
+	s.files = append(s.files, other.files...)
+	s.includes = append(s.includes, other.includes...)
+}
+
+// changed returns true if a file has changed, or an include pattern matches
+// different paths, since they were added.
+func (s *sources) changed() bool {
+	for _, stamp := range s.files {
+		var modTime time.Time
+		var size int64
+		if fi, err := os.Stat(stamp.path); err == nil {
+			modTime = fi.ModTime()
+			size = fi.Size()
+		}
+		if !modTime.Equal(stamp.modTime) || size != stamp.size {
+			return true
+		}
+	}
+	for _, match := range s.includes {
+		if paths, _ := includePaths(match.filename, match.pattern); !slices.Equal(paths, match.paths) {
+			return true
+		}
+	}
+	return false
+}
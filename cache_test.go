@@ -0,0 +1,140 @@
+package ledger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestJournalCache(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, data string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payees := func(journal *Journal) (names []string) {
+		for _, trans := range journal.Transactions {
+			names = append(names, trans.Payee+":"+trans.AccountChanges[0].Name)
+		}
+		return names
+	}
+
+	writeFile("main.dat", `account Assets:Bank
+	alias Bank
+
+include years/*.dat
+`)
+	writeFile("years/2021.dat", `2021/01/01 First
+	Bank    10
+	Equity
+`)
+	writeFile("years/2022.dat", `2022/01/01 Second
+	Bank    20
+	Equity
+`)
+
+	cache := NewJournalCache(filepath.Join(dir, "main.dat"))
+	journal, err := cache.Journal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := payees(journal); len(got) != 2 || got[0] != "First:Assets:Bank" || got[1] != "Second:Assets:Bank" {
+		t.Fatalf("unexpected transactions %v", got)
+	}
+	if again, _ := cache.Journal(); again != journal {
+		t.Error("expected the same journal when no file has changed")
+	}
+
+	entry2021 := cache.includes.entries[filepath.Join(dir, "years/2021.dat")]
+	entryMain := cache.includes.entries[filepath.Join(dir, "main.dat")]
+	writeFile("years/2022.dat", `2022/01/01 Second
+	Bank    20
+	Equity
+
+2022/02/01 Third
+	Bank    30
+	Equity
+`)
+	journal, err = cache.Journal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := payees(journal); len(got) != 3 || got[0] != "First:Assets:Bank" || got[2] != "Third:Assets:Bank" {
+		t.Fatalf("unexpected transactions after change %v", got)
+	}
+	if cache.includes.entries[filepath.Join(dir, "years/2021.dat")] != entry2021 {
+		t.Error("expected unchanged included file to not be parsed again")
+	}
+	if cache.includes.entries[filepath.Join(dir, "main.dat")] != entryMain {
+		t.Error("expected unchanged main file to not be parsed again")
+	}
+	if name := entry2021.results[0].result.transactions[0].AccountChanges[0].Name; name != "Bank" {
+		t.Errorf("expected cached posting to keep the alias, got %s", name)
+	}
+
+	writeFile("years/2023.dat", `2023/01/01 Fourth
+	Bank    40
+	Equity
+`)
+	journal, err = cache.Journal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := payees(journal); len(got) != 4 || got[3] != "Fourth:Assets:Bank" {
+		t.Fatalf("expected new included file, got %v", got)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "years/2021.dat")); err != nil {
+		t.Fatal(err)
+	}
+	journal, err = cache.Journal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := payees(journal); len(got) != 3 || got[0] != "Second:Assets:Bank" {
+		t.Fatalf("expected removed file to be dropped, got %v", got)
+	}
+	if _, found := cache.includes.entries[filepath.Join(dir, "years/2021.dat")]; found {
+		t.Error("expected removed file to be dropped from the cache")
+	}
+
+	writeFile("main.dat", `account Assets:Bank
+	alias Bank
+
+= /Bank/
+	(Budget)    *-1
+
+include years/*.dat
+`)
+	journal, err = cache.Journal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry2022 := cache.includes.entries[filepath.Join(dir, "years/2022.dat")]
+	if got := len(journal.Transactions[0].AccountChanges); got != 3 {
+		t.Fatalf("expected automated posting, got %d postings", got)
+	}
+
+	// the main file is parsed again, with the same automated transaction
+	writeFile("main.dat", `; changed
+account Assets:Bank
+	alias Bank
+
+= /Bank/
+	(Budget)    *-1
+
+include years/*.dat
+`)
+	if _, err = cache.Journal(); err != nil {
+		t.Fatal(err)
+	}
+	if cache.includes.entries[filepath.Join(dir, "years/2022.dat")] != entry2022 {
+		t.Error("expected included file to not be parsed again with the same automated transactions")
+	}
+}
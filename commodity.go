@@ -0,0 +1,205 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/howeyc/ledger/decimal"
+)
+
+var errNoAmount = errors.New("no amount")
+
+// isCommodityRune returns true for runes that may be part of a commodity
+// symbol. Digits, whitespace and characters with meaning in a posting
+// (sign, decimal point, expressions, comments, account separator) are not.
+func isCommodityRune(r rune) bool {
+	if unicode.IsDigit(r) || unicode.IsSpace(r) {
+		return false
+	}
+	switch r {
+	case '-', '+', '.', ',', ';', ':', '(', ')', '[', ']', '{', '}', '@', '=', '*', '/', '"', '\'':
+		return false
+	}
+	return true
+}
+
+// isCommodity returns true if every rune of s can be part of a commodity
+// symbol.
+func isCommodity(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for _, r := range s {
+		if !isCommodityRune(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseAmount parses a single token amount that has a commodity symbol
+// either directly before ("$50", "-$50", "$-50") or directly after ("50€")
+// the number. The number is in the format f.
+func parseAmount(f decimal.NumberFormat, s string) (amt decimal.Decimal, commodity string, err error) {
+	neg := false
+	if len(s) > 0 && s[0] == '-' {
+		neg = true
+		s = s[1:]
+	}
+
+	numStart := strings.IndexFunc(s, func(r rune) bool {
+		return !isCommodityRune(r)
+	})
+	if numStart < 0 {
+		return decimal.Zero, "", errNoAmount
+	}
+	prefix, rest := s[:numStart], s[numStart:]
+
+	numEnd := strings.LastIndexFunc(rest, func(r rune) bool {
+		return !isCommodityRune(r)
+	})
+	number, suffix := rest[:numEnd+1], rest[numEnd+1:]
+
+	switch {
+	case len(prefix) > 0 && len(suffix) == 0:
+		commodity = prefix
+	case len(prefix) == 0 && len(suffix) > 0:
+		commodity = suffix
+	default:
+		return decimal.Zero, "", errNoAmount
+	}
+
+	if neg && strings.HasPrefix(number, "-") {
+		return decimal.Zero, "", errNoAmount
+	}
+
+	amt, err = f.Parse(number)
+	if err != nil {
+		return decimal.Zero, "", err
+	}
+	if neg {
+		amt = amt.Neg()
+	}
+	return amt, commodity, nil
+}
+
+// parseCommodityAmount parses an amount that may be a plain number, a number
+// with a commodity symbol ("$150"), or a number followed by a commodity name
+// ("150 USD"), with the number in the format f.
+func parseCommodityAmount(f decimal.NumberFormat, s string) (amt decimal.Decimal, commodity string, err error) {
+	s = strings.TrimSpace(s)
+	if amt, err = f.Parse(s); err == nil {
+		return amt, "", nil
+	}
+	if amt, commodity, err = parseAmount(f, s); err == nil {
+		return amt, commodity, nil
+	}
+	if number, name, split := strings.Cut(s, " "); split {
+		name = strings.TrimSpace(name)
+		if amt, err = f.Parse(number); err == nil && isCommodity(name) {
+			return amt, name, nil
+		}
+	}
+	return decimal.Zero, "", errNoAmount
+}
+
+// parseCost parses the lot cost ({}) and price (@, @@) annotations that
+// follow the amount of a posting, with numbers in the format f.
+func parseCost(f decimal.NumberFormat, posting *Account, annotation string) error {
+	if posting.Balance.IsZero() || len(posting.Commodity) == 0 {
+		return errors.New("price annotation requires an amount with a commodity")
+	}
+
+	cost := &Cost{}
+	if rest, found := strings.CutPrefix(annotation, "{"); found {
+		lot, after, closed := strings.Cut(rest, "}")
+		if !closed {
+			return fmt.Errorf("unable to parse lot cost(%s): missing }", annotation)
+		}
+		lotPrice, lotCommodity, err := parseCommodityAmount(f, lot)
+		if err != nil {
+			return fmt.Errorf("unable to parse lot cost(%s): %w", lot, err)
+		}
+		cost.LotPrice = lotPrice
+		cost.LotCommodity = lotCommodity
+		cost.Price = lotPrice
+		cost.Commodity = lotCommodity
+		if cost.Total, err = lotPrice.CheckedMul(posting.Balance); err != nil {
+			return fmt.Errorf("unable to parse lot cost(%s): %w", lot, err)
+		}
+		annotation = strings.TrimSpace(after)
+	}
+
+	if rest, found := strings.CutPrefix(annotation, "@"); found {
+		rest, isTotal := strings.CutPrefix(rest, "@")
+		price, priceCommodity, err := parseCommodityAmount(f, rest)
+		if err != nil {
+			return fmt.Errorf("unable to parse price(%s): %w", rest, err)
+		}
+		cost.Commodity = priceCommodity
+		if isTotal {
+			cost.Total = price.Abs()
+			if posting.Balance.Sign() < 0 {
+				cost.Total = cost.Total.Neg()
+			}
+			if cost.Price, err = cost.Total.DivRound(posting.Balance, decimal.RoundHalfEven); err != nil {
+				return fmt.Errorf("unable to parse price(%s): %w", rest, err)
+			}
+		} else {
+			cost.Price = price
+			if cost.Total, err = price.CheckedMul(posting.Balance); err != nil {
+				return fmt.Errorf("unable to parse price(%s): %w", rest, err)
+			}
+		}
+		annotation = ""
+	}
+
+	if len(annotation) > 0 {
+		return fmt.Errorf("unable to parse price annotation: %s", annotation)
+	}
+
+	posting.Cost = cost
+	return nil
+}
+
+// CommodityIsPrefix returns true if the commodity symbol is written before
+// the amount. Symbols (such as $ or €) are written before the amount, while
+// names (such as AAPL or EUR) are written after the amount.
+func CommodityIsPrefix(commodity string) bool {
+	for _, r := range commodity {
+		if unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return len(commodity) > 0
+}
+
+// amountString formats an amount with its commodity symbol, with every digit
+// of the amount and at least 2 after the decimal point.
+func amountString(amt decimal.Decimal, commodity string) string {
+	s := amt.StringFixed(max(amt.Places(), 2))
+	switch {
+	case len(commodity) == 0:
+		return s
+	case CommodityIsPrefix(commodity):
+		return commodity + s
+	}
+	return s + " " + commodity
+}
+
+// Places returns the number of digits after the decimal point in the format
+// of the commodity declaration, such as 8 for "1.00000000 BTC", where the
+// decimal point is that of the number format f. It returns false if the
+// declaration has no format.
+func (c *CommodityDeclaration) Places(f decimal.NumberFormat) (int, bool) {
+	if len(c.Format) == 0 {
+		return 0, false
+	}
+	_, frac, found := strings.Cut(c.Format, string(f.DecimalPoint()))
+	if !found {
+		return 0, true
+	}
+	return len(frac) - len(strings.TrimLeftFunc(frac, unicode.IsDigit)), true
+}
@@ -0,0 +1,256 @@
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LineKind is the kind of a line in a ledger file.
+type LineKind int
+
+// Kinds of lines. Transaction, periodic, automated, and directive lines are
+// the first line of a SyntaxNode, and postings and sub-directives follow it.
+const (
+	BlankLine LineKind = iota
+	CommentLine
+	TransactionLine
+	PeriodicLine
+	AutomatedLine
+	DirectiveLine
+	SubDirectiveLine
+	PostingLine
+)
+
+// SyntaxLine is a line of a ledger file, split into its parts as written.
+// Joining the parts gives back the line.
+//
+// For a posting, Text is the account (with any status mark, parentheses, or
+// brackets) and Amount is the amount with any annotations, separated by Sep.
+// For other lines, Text is the whole line other than the comment. Space is
+// the whitespace before Comment (or at the end of the line), and Comment
+// starts with ";". Newline is the line ending as written: "\n", "\r\n", or
+// empty for a last line without one.
+type SyntaxLine struct {
+	Kind    LineKind
+	Indent  string
+	Text    string
+	Sep     string
+	Amount  string
+	Space   string
+	Comment string
+	Newline string
+}
+
+// String returns the line as written, without its line ending.
+func (l SyntaxLine) String() string {
+	return l.Indent + l.Text + l.Sep + l.Amount + l.Space + l.Comment
+}
+
+// SyntaxNode is a blank line, a comment line, or a transaction or directive
+// along with the lines that follow it (postings, sub-directives, and
+// comments).
+type SyntaxNode struct {
+	Header SyntaxLine
+	Lines  []SyntaxLine
+}
+
+// SyntaxTree holds every line of a ledger file, grouped into nodes, so that
+// writing it gives back the file. Include directives are kept as directives,
+// the included files are not read.
+type SyntaxTree struct {
+	Nodes []*SyntaxNode
+}
+
+// maxSyntaxLineSize is the longest line ParseSyntaxTree reads.
+const maxSyntaxLineSize = 16 << 20
+
+// ParseSyntaxTree reads a ledger file into a syntax tree. Lines are grouped
+// the same way as the parser, but are not checked, so a file that does not
+// parse still has a syntax tree.
+func ParseSyntaxTree(r io.Reader) (*SyntaxTree, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxSyntaxLineSize)
+	scanner.Split(scanLinesWithEnding)
+	tree := &SyntaxTree{}
+
+	var node *SyntaxNode
+	for scanner.Scan() {
+		text := scanner.Text()
+		body := strings.TrimSuffix(text, "\n")
+		body = strings.TrimSuffix(body, "\r")
+		line := splitSyntaxLine(body)
+		line.Newline = text[len(body):]
+		indented := len(line.Indent) > 0
+
+		switch {
+		case len(line.Text) == 0 && len(line.Comment) == 0:
+			// blank line ends transactions and directives
+			node = nil
+		case node != nil && node.Header.Kind == DirectiveLine && !indented:
+			// sub-directives are indented
+			node = nil
+		case node != nil && len(line.Text) > 0:
+			if node.Header.Kind == DirectiveLine {
+				line.Kind = SubDirectiveLine
+			} else {
+				line = splitPosting(line)
+			}
+			node.Lines = append(node.Lines, line)
+			continue
+		case node != nil:
+			line.Kind = CommentLine
+			node.Lines = append(node.Lines, line)
+			continue
+		}
+
+		if len(line.Text) == 0 {
+			if len(line.Comment) > 0 {
+				line.Kind = CommentLine
+			}
+			tree.Nodes = append(tree.Nodes, &SyntaxNode{Header: line})
+			continue
+		}
+
+		node = &SyntaxNode{Header: line}
+		tree.Nodes = append(tree.Nodes, node)
+		switch first, _, _ := strings.Cut(line.Text, " "); first {
+		case "account", "commodity":
+			node.Header.Kind = DirectiveLine
+		case "P", "include":
+			// single line directives
+			node.Header.Kind = DirectiveLine
+			node = nil
+		case "~":
+			node.Header.Kind = PeriodicLine
+		case "=":
+			node.Header.Kind = AutomatedLine
+		default:
+			node.Header.Kind = TransactionLine
+		}
+	}
+	return tree, scanner.Err()
+}
+
+// scanLinesWithEnding is bufio.ScanLines, but keeps the line ending as part
+// of the token.
+func scanLinesWithEnding(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// splitSyntaxLine splits a line into its indent, text, and comment.
+func splitSyntaxLine(s string) (line SyntaxLine) {
+	text := strings.TrimLeftFunc(s, unicode.IsSpace)
+	line.Indent = s[:len(s)-len(text)]
+	if commentIdx := strings.Index(text, ";"); commentIdx >= 0 {
+		line.Comment = text[commentIdx:]
+		text = text[:commentIdx]
+	}
+	line.Text = strings.TrimRightFunc(text, unicode.IsSpace)
+	line.Space = text[len(line.Text):]
+	return line
+}
+
+// splitPosting splits the text of a posting line into the account and the
+// amount, which are separated by a tab or at least two spaces.
+func splitPosting(line SyntaxLine) SyntaxLine {
+	line.Kind = PostingLine
+	iSep := strings.IndexByte(line.Text, '\t')
+	if iSpaces := strings.Index(line.Text, "  "); iSpaces >= 0 && (iSep < 0 || iSpaces < iSep) {
+		iSep = iSpaces
+	}
+	if iSep < 0 {
+		return line
+	}
+	account, amount := line.Text[:iSep], strings.TrimLeftFunc(line.Text[iSep:], unicode.IsSpace)
+	line.Sep = line.Text[len(account) : len(line.Text)-len(amount)]
+	line.Text, line.Amount = account, amount
+	return line
+}
+
+// Format aligns the amounts of postings, so that the quantity of each amount
+// ends at column, with any price, lot cost, or balance assertion following
+// it. Accounts longer than the column are followed by two spaces. Other
+// lines are unchanged, other than whitespace at the end of a posting.
+func (t *SyntaxTree) Format(column int) {
+	for _, node := range t.Nodes {
+		for i := range node.Lines {
+			line := &node.Lines[i]
+			if line.Kind != PostingLine || len(line.Amount) == 0 {
+				continue
+			}
+			quantity := line.Amount
+			if iAnnot := annotationIndex(line.Amount); iAnnot >= 0 {
+				quantity = strings.TrimRightFunc(line.Amount[:iAnnot], unicode.IsSpace)
+			}
+			width := displayWidth(line.Indent+line.Text) + utf8.RuneCountInString(quantity)
+			line.Sep = strings.Repeat(" ", max(column-width, 2))
+			if len(line.Comment) == 0 {
+				line.Space = ""
+			}
+		}
+	}
+}
+
+// annotationIndex returns the index of the first price (@), lot cost ({), or
+// balance assertion (=) after the quantity of an amount, or -1 if there is
+// none.
+func annotationIndex(amount string) int {
+	for i := 1; i < len(amount); i++ {
+		switch amount[i] {
+		case '@', '{', '=':
+			if unicode.IsSpace(rune(amount[i-1])) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// displayWidth returns the width of s, with tabs to the next multiple of 8.
+func displayWidth(s string) (width int) {
+	for _, r := range s {
+		if r == '\t' {
+			width += 8 - width%8
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// WriteTo writes the lines of the syntax tree to w, each followed by its
+// line ending.
+func (t *SyntaxTree) WriteTo(w io.Writer) (n int64, err error) {
+	bw := bufio.NewWriter(w)
+	write := func(line SyntaxLine) {
+		if err != nil {
+			return
+		}
+		var written int
+		written, err = bw.WriteString(line.String() + line.Newline)
+		n += int64(written)
+	}
+	for _, node := range t.Nodes {
+		write(node.Header)
+		for _, line := range node.Lines {
+			write(line)
+		}
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
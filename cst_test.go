@@ -0,0 +1,122 @@
+package ledger
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyntaxTreeRoundTrip(t *testing.T) {
+	inputs := make(map[string]string)
+	for _, tc := range testCases {
+		inputs[tc.name] = tc.data
+	}
+	filenames, _ := filepath.Glob("testdata/*.dat")
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs[filename] = string(data)
+	}
+	inputs["crlf"] = "2024/01/02 Shop  ; note\r\n\tExpenses:Food    $5   \r\n\tAssets:Cash\r\n\r\n; end"
+	inputs["long line"] = "; " + strings.Repeat("x", 100000) + "\n"
+
+	for name, data := range inputs {
+		tree, err := ParseSyntaxTree(strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("Error(%s): %s", name, err)
+		}
+		var buf bytes.Buffer
+		if _, err := tree.WriteTo(&buf); err != nil {
+			t.Fatalf("Error(%s): %s", name, err)
+		}
+		if buf.String() != data {
+			t.Errorf("Error(%s): expected \n`%s`, \ngot \n`%s`", name, data, buf.String())
+		}
+	}
+}
+
+func TestSyntaxTreeFormat(t *testing.T) {
+	data := `; header comment
+account Assets:Checking   ; main
+	alias chk
+include other.ledger
+
+2024/01/02 * Landlord   ; Project: home
+	; indented comment
+	Expenses:Rent    1,000.00 EUR @ $1.10  ; rent
+  ! Assets:Checking     = $5000   
+	(Budget:Rent)	-1000
+	Liabilities
+
+~ Monthly  Rent
+    Expenses:Rent  1000
+    Assets:Checking
+`
+	expected := `; header comment
+account Assets:Checking   ; main
+	alias chk
+include other.ledger
+
+2024/01/02 * Landlord   ; Project: home
+	; indented comment
+	Expenses:Rent           1,000.00 EUR @ $1.10  ; rent
+  ! Assets:Checking                  = $5000
+	(Budget:Rent)                  -1000
+	Liabilities
+
+~ Monthly  Rent
+    Expenses:Rent                       1000
+    Assets:Checking
+`
+	tree, err := ParseSyntaxTree(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var kinds []LineKind
+	for _, node := range tree.Nodes {
+		kinds = append(kinds, node.Header.Kind)
+		for _, line := range node.Lines {
+			kinds = append(kinds, line.Kind)
+		}
+	}
+	expKinds := []LineKind{
+		CommentLine, DirectiveLine, SubDirectiveLine, DirectiveLine, BlankLine,
+		TransactionLine, CommentLine, PostingLine, PostingLine, PostingLine, PostingLine, BlankLine,
+		PeriodicLine, PostingLine, PostingLine,
+	}
+	if len(kinds) != len(expKinds) {
+		t.Fatalf("expected %d lines, got %d", len(expKinds), len(kinds))
+	}
+	for i := range kinds {
+		if kinds[i] != expKinds[i] {
+			t.Errorf("line %d: expected kind %d, got %d", i+1, expKinds[i], kinds[i])
+		}
+	}
+
+	tree.Format(44)
+	var buf bytes.Buffer
+	if _, err := tree.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != expected {
+		t.Errorf("expected \n`%s`, \ngot \n`%s`", expected, buf.String())
+	}
+
+	tree, err = ParseSyntaxTree(strings.NewReader("2024/01/02 Shop\r\n\tExpenses:Food  $5   \r\n\tAssets:Cash\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Format(30)
+	buf.Reset()
+	if _, err := tree.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "2024/01/02 Shop\r\n\tExpenses:Food       $5\r\n\tAssets:Cash\r\n"; buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
@@ -1,6 +1,10 @@
 package ledger
 
-import "time"
+import (
+	"maps"
+	"slices"
+	"time"
+)
 
 // TransactionsInDateRange returns a new array of transactions that are in the date range
 // specified by start and end. The returned list contains transactions on the same day as start
@@ -94,6 +98,91 @@ func getDateBoundaries(per Period, start, end time.Time) []time.Time {
 	return boundaries
 }
 
+// repeatDates returns the dates from anchor, repeating every period, that are
+// before end. Monthly repeats fall on the day of the month of anchor, or the
+// last day of shorter months.
+func repeatDates(per Period, anchor, end time.Time) []time.Time {
+	var incDays, incMonth int
+	switch per {
+	case PeriodDay:
+		incDays = 1
+	case PeriodWeek:
+		incDays = 7
+	case Period2Week:
+		incDays = 14
+	case PeriodMonth:
+		incMonth = 1
+	case Period2Month:
+		incMonth = 2
+	case PeriodQuarter:
+		incMonth = 3
+	case PeriodSemiYear:
+		incMonth = 6
+	case PeriodYear:
+		incMonth = 12
+	default:
+		return []time.Time{anchor}
+	}
+
+	var dates []time.Time
+	for n := 0; ; n++ {
+		date := anchor.AddDate(0, n*incMonth, n*incDays)
+		if date.Day() != anchor.Day() && incMonth > 0 {
+			// past the end of a shorter month
+			date = date.AddDate(0, 0, -date.Day())
+		}
+		if !date.Before(end) {
+			return dates
+		}
+		dates = append(dates, date)
+	}
+}
+
+// Forecast returns the transactions generated by the periodic transactions,
+// from start until end (not including end). A periodic transaction repeats
+// from its start date (from), or on the first day of each period without one.
+// Generated transactions have the forecast tag.
+func Forecast(periodic []*PeriodicTransaction, start, end time.Time) []*Transaction {
+	var results []*Transaction
+	for _, pt := range periodic {
+		from, until := start, end
+		if pt.Start.After(from) {
+			from = pt.Start
+		}
+		if !pt.End.IsZero() && pt.End.Before(until) {
+			until = pt.End
+		}
+		if !from.Before(until) {
+			continue
+		}
+
+		dates := getDateBoundaries(pt.Period, from, until)
+		if !pt.Start.IsZero() {
+			dates = repeatDates(pt.Period, pt.Start, until)
+		}
+		for _, date := range dates {
+			if date.Before(from) || !date.Before(until) {
+				continue
+			}
+			trans := pt.Transaction
+			trans.Date = date
+			trans.AccountChanges = slices.Clone(pt.AccountChanges)
+			trans.Comments = append(slices.Clip(pt.Comments), "; :forecast:")
+			trans.Tags = maps.Clone(pt.Tags)
+			if trans.Tags == nil {
+				trans.Tags = make(map[string]string)
+			}
+			trans.Tags["forecast"] = ""
+			results = append(results, &trans)
+		}
+	}
+
+	slices.SortStableFunc(results, func(a, b *Transaction) int {
+		return a.Date.Compare(b.Date)
+	})
+	return results
+}
+
 // RangeType is used to specify how the data is "split" into sections
 type RangeType string
 
@@ -162,8 +251,9 @@ type RangeBalance struct {
 	Balances   []*Account
 }
 
-// BalancesByPeriod will return the account balances for each period.
-func BalancesByPeriod(trans []*Transaction, per Period, rType RangeType) []*RangeBalance {
+// BalancesByPeriod will return the account balances for each period. Returns
+// an error if a balance does not fit in a decimal.Decimal.
+func BalancesByPeriod(trans []*Transaction, per Period, rType RangeType) ([]*RangeBalance, error) {
 	tStart, tEnd := startEndTime(trans)
 
 	boundaries := getDateBoundaries(per, tStart, tEnd)
@@ -174,13 +264,34 @@ func BalancesByPeriod(trans []*Transaction, per Period, rType RangeType) []*Rang
 		bEnd := boundary
 
 		bTrans := TransactionsInDateRange(trans, bStart, bEnd)
+		balances, err := GetBalances(bTrans, []string{})
+		if err != nil {
+			return nil, err
+		}
 		// End date should be the last day (inclusive, so subtract 1 day)
-		results = append(results, &RangeBalance{Start: bStart, End: bEnd.AddDate(0, 0, -1), Balances: GetBalances(bTrans, []string{})})
+		results = append(results, &RangeBalance{Start: bStart, End: bEnd.AddDate(0, 0, -1), Balances: balances})
 
 		if rType == RangePartition {
 			bStart = bEnd
 		}
 	}
 
-	return results
+	return results, nil
+}
+
+// MarketBalancesByPeriod will return the account balances for each period,
+// with the balances valued in the target commodity at the end of each period.
+// See PriceHistory.MarketBalances.
+func MarketBalancesByPeriod(trans []*Transaction, per Period, rType RangeType, prices PriceHistory, target string) ([]*RangeBalance, error) {
+	results, err := BalancesByPeriod(trans, per, rType)
+	if err != nil {
+		return nil, err
+	}
+	for _, rb := range results {
+		// End is inclusive, prices during the last day count
+		if rb.Balances, err = prices.MarketBalances(rb.Balances, target, rb.End.AddDate(0, 0, 1).Add(-time.Nanosecond)); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
 }
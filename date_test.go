@@ -1,6 +1,9 @@
 package ledger
 
 import (
+	"bytes"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -159,3 +162,87 @@ func TestDateBoundaries(t *testing.T) {
 		}
 	}
 }
+
+func TestForecast(t *testing.T) {
+	journal, err := ParseJournal(bytes.NewBufferString(`~ Monthly from 2024/01/01 to 2024/04/01  Rent
+	Expenses:Rent     1000
+	Assets:Checking
+
+~ Quarterly
+	Assets:Savings      10  ; Interest: yes
+	Income:Interest
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journal.Periodic) != 2 || journal.Periodic[0].Payee != "Rent" || journal.Periodic[1].Period != PeriodQuarter {
+		t.Fatal("periodic transactions not parsed")
+	}
+
+	trans := Forecast(journal.Periodic,
+		time.Date(2024, time.February, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC))
+
+	expected := []struct {
+		date  time.Time
+		payee string
+	}{
+		{time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), "Rent"},
+		{time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC), ""},
+	}
+	if len(trans) != len(expected) {
+		t.Fatalf("Error: expected `%d` transactions, got `%d`", len(expected), len(trans))
+	}
+	for i, tc := range expected {
+		if !trans[i].Date.Equal(tc.date) || trans[i].Payee != tc.payee {
+			t.Errorf("Error: expected [%d] = `%s %s`, got `%s %s`", i, tc.date.Format(time.DateOnly), tc.payee, trans[i].Date.Format(time.DateOnly), trans[i].Payee)
+		}
+		if _, found := trans[i].Tags["forecast"]; !found {
+			t.Errorf("Error: expected [%d] to have forecast tag", i)
+		}
+	}
+	if journal.Periodic[0].Tags != nil {
+		t.Error("forecast tag added to periodic transaction")
+	}
+}
+
+func TestForecastFrom(t *testing.T) {
+	journal, err := ParseJournal(bytes.NewBufferString(`~ Monthly from 2024/01/15  Rent
+	Expenses:Rent     1000
+	Assets:Checking
+
+~ Monthly from 2024/01/31  Card
+	Liabilities:Card   100
+	Assets:Checking
+
+~ BiWeekly from 2024/01/05  Pay
+	Assets:Checking   2000
+	Income:Salary
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trans := Forecast(journal.Periodic,
+		time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC))
+
+	var got []string
+	for _, tr := range trans {
+		got = append(got, tr.Date.Format(time.DateOnly)+" "+tr.Payee)
+	}
+	exp := []string{
+		"2024-02-02 Pay",
+		"2024-02-15 Rent",
+		"2024-02-16 Pay",
+		"2024-02-29 Card",
+		"2024-03-01 Pay",
+		"2024-03-15 Rent",
+		"2024-03-15 Pay",
+		"2024-03-29 Pay",
+		"2024-03-31 Card",
+	}
+	if !slices.Equal(exp, got) {
+		t.Errorf("expected \n`%s`, \ngot \n`%s`", strings.Join(exp, "\n"), strings.Join(got, "\n"))
+	}
+}
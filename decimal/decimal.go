@@ -1,58 +1,82 @@
 // Package decimal implements fixed-point decimal with accuracy to 3 digits of
-// precision after the decimal point.
+// precision after the decimal point, or up to 9 digits when more are given.
 //
-// int64 is the underlying data type for speed of computation. However, using
-// an int64 cast to Decimal will not work, one of the "New" functions must
-// be used to get accurate results.
+// int64 is the underlying data type for speed of computation. One of the "New"
+// functions must be used to get a Decimal.
 //
-// The package multiplies every source value by 1000, and then does integer
-// math from that point forward, maintaining all values at that scale over
-// every operation.
+// Each Decimal holds the number of digits it keeps after the decimal point,
+// its scale. The value is multiplied by 10 to the power of the scale, such as
+// 1000 for the default of 3 digits, and integer math is done from that point
+// forward. The result of an operation keeps the larger scale of its operands.
 //
-// Note: For use in ledger. Cannot handle values over approx 900 trillion.
+// Note: For use in ledger. Cannot handle values over approx 900 trillion, and
+// less with more digits after the decimal point.
 package decimal
 
 import (
 	"errors"
+	"math"
+	"math/bits"
 	"strconv"
 	"strings"
 )
 
-// Decimal represents a fixed-point decimal.
-type Decimal int64
+// Decimal represents a fixed-point decimal. The zero value is 0.
+type Decimal struct {
+	// value is the decimal multiplied by 10 to the power of the scale.
+	value int64
+	// extra is the number of digits kept after the first 3.
+	extra uint8
+}
+
+// defaultPrecision of 3 digits, the least scale of a Decimal
+const defaultPrecision = 3
+
+// maxPrecision leaves a whole number part of at least 9 digits.
+const maxPrecision = 9
 
-// scaleFactor used for math operations,
-const scaleFactor = 1000
+// pow10 are the powers of 10 up to a product of two scales.
+var pow10 = [2*maxPrecision + 1]uint64{
+	1, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9,
+	1e10, 1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18,
+}
 
-// precision of 3 digits
-const precision = 3
+// Zero, to make initializations easier.
+var Zero = Decimal{}
 
-// Zero constant, to make initializations easier.
-const Zero = Decimal(0)
+// One, to make initializations easier.
+var One = Decimal{value: 1000}
 
-// One constant, to make initializations easier.
-const One = Decimal(scaleFactor)
+// pack returns the Decimal of the value m at the scale.
+func pack(m int64, scale int) Decimal {
+	return Decimal{value: m, extra: uint8(scale - defaultPrecision)}
+}
 
-// Parse max/min for whole number part
-const parseMax = (1<<63 - 1) / scaleFactor
-const parseMin = (-1 << 63) / scaleFactor
+// scale returns the number of digits kept after the decimal point.
+func (d Decimal) scale() int {
+	return defaultPrecision + int(d.extra)
+}
 
 // NewFromFloat converts a float64 to Decimal. Only 3 digits of precision after
 // the decimal point are preserved.
 func NewFromFloat(f float64) Decimal {
-	return Decimal(f * float64(scaleFactor))
+	return pack(int64(f*1000), defaultPrecision)
 }
 
 // NewFromInt converts a int64 to Decimal. Multiplies by 1000 to get into
 // Decimal scale.
 func NewFromInt(i int64) Decimal {
-	return Decimal(i) * scaleFactor
+	return pack(i*1000, defaultPrecision)
 }
 
 var errEmpty = errors.New("empty string")
 var errTooBig = errors.New("number too big")
 var errInvalid = errors.New("invalid syntax")
 
+// ErrOverflow is returned when the result of an operation does not fit in a
+// Decimal.
+var ErrOverflow = errors.New("decimal overflow")
+
 // atoi64 is equivalent to strconv.Atoi
 func atoi64(s string) (bool, int64, error) {
 	sLen := len(s)
@@ -87,8 +111,15 @@ func atoi64(s string) (bool, int64, error) {
 }
 
 // NewFromString returns a Decimal from a string representation. Throws an
-// error if integer parsing fails.
+// error if integer parsing fails. Only 3 digits of precision after the
+// decimal point are preserved.
 func NewFromString(s string) (Decimal, error) {
+	return newFromString(s, defaultPrecision)
+}
+
+// newFromString returns a Decimal from a string representation, keeping up
+// to digits digits after the decimal point, and at least 3.
+func newFromString(s string, digits int) (Decimal, error) {
 	if whole, frac, split := strings.Cut(s, "."); split {
 		neg, w, err := atoi64(whole)
 		// if fractional portion exists, whole part can be empty
@@ -96,53 +127,50 @@ func NewFromString(s string) (Decimal, error) {
 			return Zero, err
 		}
 
-		// overflow
-		if w > parseMax || w < parseMin {
-			return Zero, errTooBig
-		}
-		w *= int64(scaleFactor)
-
-		// Parse up to *precision* digits and scale up
+		// Parse up to *digits* digits
 		var f int64
 		var seen int
 		for _, b := range frac {
-			f *= 10
+			if seen == digits {
+				break
+			}
 			if b < '0' || b > '9' {
 				return Zero, errInvalid
 			}
-			f += int64(b - '0')
+			f = f*10 + int64(b-'0')
 			seen++
-			if seen == precision {
-				break
-			}
 		}
-		for seen < precision {
-			f *= 10
-			seen++
+		scale := max(seen, defaultPrecision)
+		f *= int64(pow10[scale-seen])
+
+		// overflow
+		unit := int64(pow10[scale])
+		if w > math.MaxInt64/unit || w < math.MinInt64/unit {
+			return Zero, errTooBig
 		}
+		w *= unit
 
 		if neg {
 			f = -f
 		}
-		return Decimal(w + f), nil
+		return pack(w+f, scale), nil
 	}
 
 	_, i, err := atoi64(s)
-	if i > parseMax || i < parseMin {
+	if i > math.MaxInt64/1000 || i < math.MinInt64/1000 {
 		return Zero, errTooBig
 	}
-	i *= int64(scaleFactor)
-	return Decimal(i), err
+	return pack(i*1000, defaultPrecision), err
 }
 
 // IsZero returns true if d == 0
 func (d Decimal) IsZero() bool {
-	return d == Zero
+	return d.value == 0
 }
 
 // Neg returns -d
 func (d Decimal) Neg() Decimal {
-	return -d
+	return pack(-d.value, d.scale())
 }
 
 // Sign returns:
@@ -153,46 +181,179 @@ func (d Decimal) Neg() Decimal {
 //
 // +1 if d >  0
 func (d Decimal) Sign() int {
-	if d < 0 {
+	if m := d.value; m < 0 {
 		return -1
-	} else if d > 0 {
+	} else if m > 0 {
 		return 1
 	}
 	return 0
 }
 
-// Add returns d + d1
+// Add returns d + d1. It panics with ErrOverflow if the sum does not fit in
+// a Decimal, use CheckedAdd to get the error instead.
 func (d Decimal) Add(d1 Decimal) Decimal {
-	return d + d1
+	sum, err := d.CheckedAdd(d1)
+	if err != nil {
+		panic(err)
+	}
+	return sum
 }
 
-// Sub returns d - d1
+// Sub returns d - d1. It panics with ErrOverflow if the difference does not
+// fit in a Decimal, use CheckedSub to get the error instead.
 func (d Decimal) Sub(d1 Decimal) Decimal {
-	return d - d1
+	diff, err := d.CheckedSub(d1)
+	if err != nil {
+		panic(err)
+	}
+	return diff
 }
 
-// Mul returns d * d1
+// Mul returns d * d1, truncated toward zero. It panics with ErrOverflow if
+// the product does not fit in a Decimal, use CheckedMul or MulRound to get
+// the error instead.
 func (d Decimal) Mul(d1 Decimal) Decimal {
-	return (d * d1) / scaleFactor
+	m, err := d.MulRound(d1, RoundDown)
+	if err != nil {
+		panic(err)
+	}
+	return m
 }
 
-// Div returns d / d1
+// Div returns d / d1, truncated toward zero. It panics with ErrDivideByZero
+// if d1 is zero, or ErrOverflow if the quotient does not fit in a Decimal,
+// use CheckedDiv or DivRound to get the error instead.
 func (d Decimal) Div(d1 Decimal) Decimal {
-	return (d * scaleFactor) / d1
+	q, err := d.DivRound(d1, RoundDown)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// CheckedAdd returns d + d1, or ErrOverflow if the sum does not fit in a
+// Decimal.
+func (d Decimal) CheckedAdd(d1 Decimal) (Decimal, error) {
+	m, m1, scale, ok := align(d, d1)
+	sum := m + m1
+	if !ok || (sum > m) != (m1 > 0) {
+		return Zero, ErrOverflow
+	}
+	return pack(sum, scale), nil
+}
+
+// CheckedSub returns d - d1, or ErrOverflow if the difference does not fit
+// in a Decimal.
+func (d Decimal) CheckedSub(d1 Decimal) (Decimal, error) {
+	m, m1, scale, ok := align(d, d1)
+	diff := m - m1
+	if !ok || (diff < m) != (m1 > 0) {
+		return Zero, ErrOverflow
+	}
+	return pack(diff, scale), nil
+}
+
+// CheckedMul returns d * d1, truncated toward zero, or ErrOverflow if the
+// product does not fit in a Decimal.
+func (d Decimal) CheckedMul(d1 Decimal) (Decimal, error) {
+	return d.MulRound(d1, RoundDown)
+}
+
+// CheckedDiv returns d / d1, truncated toward zero, ErrDivideByZero if d1 is
+// zero, or ErrOverflow if the quotient does not fit in a Decimal.
+func (d Decimal) CheckedDiv(d1 Decimal) (Decimal, error) {
+	return d.DivRound(d1, RoundDown)
+}
+
+// align returns the values of d and d1 at the larger of their scales, and
+// false if the value with the smaller scale does not fit at the larger one.
+func align(d, d1 Decimal) (m, m1 int64, scale int, ok bool) {
+	m, m1 = d.value, d1.value
+	scale, scale1 := d.scale(), d1.scale()
+	switch {
+	case scale < scale1:
+		m, ok = rescale(m, scale1-scale)
+		return m, m1, scale1, ok
+	case scale > scale1:
+		m1, ok = rescale(m1, scale-scale1)
+		return m, m1, scale, ok
+	}
+	return m, m1, scale, true
+}
+
+// rescale returns m multiplied by 10 to the power of digits, and false if
+// the result does not fit in a Decimal.
+func rescale(m int64, digits int) (int64, bool) {
+	hi, lo := bits.Mul64(absMant(m), pow10[digits])
+	if hi != 0 {
+		return 0, false
+	}
+	return mantFromAbs(lo, m < 0)
+}
+
+// absMant returns the absolute value of m as a uint64.
+func absMant(m int64) uint64 {
+	u := uint64(m)
+	if m < 0 {
+		u = -u
+	}
+	return u
+}
+
+// abs returns the absolute value of d at its scale as a uint64.
+func (d Decimal) abs() uint64 {
+	return absMant(d.value)
+}
+
+// mantFromAbs returns the value of the absolute value u with the sign, and
+// false if it does not fit in a Decimal.
+func mantFromAbs(u uint64, neg bool) (int64, bool) {
+	switch {
+	case neg && u <= 1<<63:
+		return int64(-u), true
+	case !neg && u < 1<<63:
+		return int64(u), true
+	}
+	return 0, false
+}
+
+// fromAbs returns the Decimal of the absolute value u at the scale with the
+// sign, or ErrOverflow if it does not fit.
+func fromAbs(u uint64, neg bool, scale int) (Decimal, error) {
+	m, ok := mantFromAbs(u, neg)
+	if !ok {
+		return Zero, ErrOverflow
+	}
+	return pack(m, scale), nil
 }
 
 // Abs returns the absolute value of the decimal
 func (d Decimal) Abs() Decimal {
-	if d < 0 {
+	if d.value < 0 {
 		return d.Neg()
 	}
 	return d
 }
 
+// Places returns the number of digits after the decimal point needed to
+// write d exactly, at most its scale.
+//
+// Example:
+//
+// NewFromFloat(5.5).Places() == 1
+// NewFromInt(5).Places() == 0
+func (d Decimal) Places() int {
+	places := d.scale()
+	for u := d.abs(); places > 0 && u%10 == 0; u /= 10 {
+		places--
+	}
+	return places
+}
+
 // Float64 returns the float64 value for d, and exact is always set to false.
 // The signature is this way to match big.Rat
 func (d Decimal) Float64() (f float64, exact bool) {
-	return float64(d) / float64(scaleFactor), false
+	return float64(d.value) / float64(pow10[d.scale()]), false
 }
 
 // Cmp compares the numbers represented by d and d1 and returns:
@@ -201,19 +362,32 @@ func (d Decimal) Float64() (f float64, exact bool) {
 //	 0 if d == d1
 //	+1 if d >  d1
 func (d Decimal) Cmp(d1 Decimal) int {
-	if d < d1 {
+	m, m1, _, ok := align(d, d1)
+	if !ok {
+		// the value with the smaller scale is larger than any at the
+		// larger scale
+		if d.scale() < d1.scale() {
+			return d.Sign()
+		}
+		return -d1.Sign()
+	}
+	if m < m1 {
 		return -1
-	} else if d > d1 {
+	} else if m > m1 {
 		return 1
 	}
 	return 0
 }
 
 // fmtFrac formats the fraction of v/10**prec (e.g., ".12345") into the
-// tail of buf. It returns the index where the
-// output bytes begin and the value v/10**prec.
-func fmtFrac(buf []byte, v uint64, prec int) (nw int, nv uint64) {
+// tail of buf, padded with zeros to places digits. It returns the index where
+// the output bytes begin and the value v/10**prec.
+func fmtFrac(buf []byte, v uint64, prec, places int) (nw int, nv uint64) {
 	w := len(buf)
+	for range places - prec {
+		w--
+		buf[w] = '0'
+	}
 	for range prec {
 		digit := v % 10
 		w--
@@ -250,7 +424,7 @@ func fmtInt(buf []byte, v uint64) int {
 // NewFromFloat(5.455).StringFixedBank() == "5.46"
 // NewFromFloat(5.445).StringFixedBank() == "5.44"
 func (d Decimal) StringFixedBank() string {
-	var buf [24]byte
+	var buf [32]byte
 	n := d.FixedBank(buf[:])
 	return string(buf[n:])
 }
@@ -258,26 +432,50 @@ func (d Decimal) StringFixedBank() string {
 // FixedBank writes a banker rounded fixed-point string with 2 digits
 // after the decimal point to the passed in byte array.
 func (d Decimal) FixedBank(buf []byte) (n int) {
+	return d.Fixed(buf, 2)
+}
+
+// StringFixed returns a banker rounded fixed-point string with places digits
+// after the decimal point, up to 9.
+//
+// Example:
+//
+// NewFromFloat(5.455).StringFixed(1) == "5.5"
+// NewFromFloat(5.455).StringFixed(3) == "5.455"
+func (d Decimal) StringFixed(places int) string {
+	var buf [32]byte
+	n := d.Fixed(buf[:], places)
+	return string(buf[n:])
+}
+
+// Fixed writes a banker rounded fixed-point string with places digits after
+// the decimal point, up to 9, to the passed in byte array. Digits beyond the
+// scale of d are zero.
+func (d Decimal) Fixed(buf []byte, places int) (n int) {
 	w := len(buf)
+	places = min(max(places, 0), maxPrecision)
+	prec := d.scale()
 
-	u := uint64(d)
-	neg := d < 0
-	if neg {
-		u = -u
-	}
+	u := d.abs()
 
 	// Bank rounding
-	rem := u % 10
-	u /= 10
-	if rem > 5 || (rem == 5 && u%2 != 0) {
-		u++
+	if prec > places {
+		unit := pow10[prec-places]
+		rem, half := u%unit, unit/2
+		u /= unit
+		if rem > half || (rem == half && u%2 != 0) {
+			u++
+		}
+		prec = places
 	}
 
 	// fmt functions from time.Duration
-	w, u = fmtFrac(buf[:w], u, precision-1)
+	if places > 0 {
+		w, u = fmtFrac(buf[:w], u, prec, places)
+	}
 	w = fmtInt(buf[:w], u)
 
-	if neg {
+	if d.value < 0 {
 		w--
 		buf[w] = '-'
 	}
@@ -291,8 +489,8 @@ func (d Decimal) FixedBank(buf []byte) (n int) {
 //
 // NewFromFloat(5.44).StringTruncate() == "5"
 func (d Decimal) StringTruncate() string {
-	whole := d / scaleFactor
-	return strconv.FormatInt(int64(whole), 10)
+	whole := d.value / int64(pow10[d.scale()])
+	return strconv.FormatInt(whole, 10)
 }
 
 // StringRound returns the nearest rounded whole-number (Int) part of d.
@@ -303,19 +501,39 @@ func (d Decimal) StringTruncate() string {
 // NewFromFloat(-5.4).StringRound() == "5"
 // NewFromFloat(-5.5).StringRound() == "6"
 func (d Decimal) StringRound() string {
-	whole := d / scaleFactor
-	frac := (d % scaleFactor)
+	unit := int64(pow10[d.scale()])
+	whole := d.value / unit
+	frac := d.value % unit
 	neg := false
 	if frac < 0 {
 		frac = -frac
 		neg = true
 	}
-	if frac >= (5 * (scaleFactor / 10)) {
+	if unit > 1 && frac >= 5*(unit/10) {
 		if neg {
 			whole--
 		} else {
 			whole++
 		}
 	}
-	return strconv.FormatInt(int64(whole), 10)
+	return strconv.FormatInt(whole, 10)
+}
+
+// MarshalJSON returns d as a JSON number with every digit it keeps after the
+// decimal point.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	var buf [32]byte
+	n := d.Fixed(buf[:], d.scale())
+	return append([]byte(nil), buf[n:]...), nil
+}
+
+// UnmarshalJSON sets d from a JSON number, keeping up to 9 digits after the
+// decimal point.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	v, err := newFromString(strings.Trim(string(data), `"`), maxPrecision)
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
 }
@@ -1,6 +1,8 @@
 package decimal
 
 import (
+	"encoding/json"
+	"math"
 	"math/rand"
 	"strings"
 	"testing"
@@ -180,6 +182,259 @@ func TestSign(t *testing.T) {
 	}
 }
 
+func TestPrecision(t *testing.T) {
+	d, err := Parse("0.12345678")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.StringFixed(8) != "0.12345678" || d.StringFixedBank() != "0.12" || d.StringFixed(5) != "0.12346" {
+		t.Errorf("precision 8: got `%s`", d.StringFixed(8))
+	}
+	if p := d.Mul(NewFromInt(2)); p.StringFixed(8) != "0.24691356" {
+		t.Errorf("precision 8 multiply: got `%s`", p.StringFixed(8))
+	}
+	if sum := d.Add(NewFromFloat(1.5)); sum.StringFixed(9) != "1.623456780" {
+		t.Errorf("precision 8 add: got `%s`", sum.StringFixed(9))
+	}
+	if p := NewFromFloat(4321.5).Mul(d); p.StringFixed(8) != "533.51847477" {
+		t.Errorf("precision 8 multiply price: got `%s`", p.StringFixed(8))
+	}
+	if q := NewFromInt(1).Div(d); q.StringFixed(8) != "8.10000066" {
+		t.Errorf("precision 8 divide: got `%s`", q.StringFixed(8))
+	}
+	if d.Cmp(NewFromFloat(0.123)) != 1 || NewFromFloat(0.123).Cmp(d) != -1 {
+		t.Error("precision 8 compare")
+	}
+	if half, _ := Parse("0.50000000"); NewFromFloat(0.5).Cmp(half) != 0 {
+		t.Error("precision 8 compare equal")
+	}
+	if _, err := Parse("100000000000.12345678"); err != errTooBig {
+		t.Errorf("precision 8 parse max: expected `%s`, got `%v`", errTooBig, err)
+	}
+	if d, _ := Parse("0.1234567891"); d.StringFixed(9) != "0.123456789" {
+		t.Errorf("precision 9: got `%s`", d.StringFixed(9))
+	}
+	if d, _ := NewFromString("0.12345678"); d.StringFixed(8) != "0.12300000" {
+		t.Errorf("precision 3: got `%s`", d.StringFixed(8))
+	}
+	for s, places := range map[string]int{"0.12345678": 8, "0.10000000": 1, "-4938.27": 2, "40000": 0, "0": 0} {
+		if d, _ := Parse(s); d.Places() != places {
+			t.Errorf("places of %s: expected %d, got %d", s, places, d.Places())
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	d, _ := Parse("-1234.12345678")
+	data, err := json.Marshal([]Decimal{d, One})
+	if err != nil || string(data) != "[-1234.12345678,1.000]" {
+		t.Fatalf("marshal: got `%s`, `%v`", data, err)
+	}
+	var ds []Decimal
+	if err := json.Unmarshal(data, &ds); err != nil || ds[0] != d || ds[1] != One {
+		t.Errorf("unmarshal: got %v, `%v`", ds, err)
+	}
+}
+
+func TestStringFixed(t *testing.T) {
+	d := NewFromFloat(-5.455)
+	for places, result := range []string{"-5", "-5.5", "-5.46", "-5.455", "-5.4550"} {
+		if d.StringFixed(places) != result {
+			t.Errorf("places %d: expected `%s`, got `%s`", places, result, d.StringFixed(places))
+		}
+	}
+}
+
+func TestChecked(t *testing.T) {
+	big := Decimal{value: math.MaxInt64 - 1}
+	if _, err := big.CheckedAdd(NewFromInt(1)); err != ErrOverflow {
+		t.Errorf("add: expected overflow, got `%v`", err)
+	}
+	if _, err := big.Neg().CheckedSub(NewFromInt(1)); err != ErrOverflow {
+		t.Errorf("sub: expected overflow, got `%v`", err)
+	}
+	if _, err := NewFromInt(1e9).CheckedMul(NewFromInt(1e9)); err != ErrOverflow {
+		t.Errorf("mul: expected overflow, got `%v`", err)
+	}
+
+	if d, err := NewFromInt(5).CheckedAdd(NewFromInt(-7)); err != nil || d != NewFromInt(-2) {
+		t.Errorf("add: got `%s`, `%v`", d.StringFixedBank(), err)
+	}
+	if d, err := NewFromInt(-5).CheckedSub(NewFromInt(-7)); err != nil || d != NewFromInt(2) {
+		t.Errorf("sub: got `%s`, `%v`", d.StringFixedBank(), err)
+	}
+	// the intermediate product overflows an int64, but not the result
+	if d, err := NewFromInt(1e7).CheckedMul(NewFromInt(-1e7)); err != nil || d != NewFromInt(-1e14) {
+		t.Errorf("mul: got `%s`, `%v`", d.StringFixedBank(), err)
+	}
+}
+
+func TestPanic(t *testing.T) {
+	big := Decimal{value: math.MaxInt64 - 1}
+	for name, op := range map[string]func(){
+		"add":            func() { big.Add(One) },
+		"sub":            func() { big.Neg().Sub(One.Add(One)) },
+		"mul":            func() { NewFromInt(1e9).Mul(NewFromInt(1e9)) },
+		"div":            func() { NewFromInt(1e13).Div(NewFromFloat(0.001)) },
+		"divide by zero": func() { One.Div(Zero) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != ErrOverflow && r != ErrDivideByZero {
+					t.Errorf("%s: expected panic, got `%v`", name, r)
+				}
+			}()
+			op()
+		}()
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   RoundingMode
+		d, d1  float64
+		mul    string
+		div    string
+		negMul string
+	}{
+		{"down", RoundDown, 2.5, 0.001, "0.002", "2500.000", "-0.002"},
+		{"half-even", RoundHalfEven, 2.5, 0.001, "0.002", "2500.000", "-0.002"},
+		{"half-even-odd", RoundHalfEven, 3.5, 0.001, "0.004", "3500.000", "-0.004"},
+		{"half-up", RoundHalfUp, 2.5, 0.001, "0.003", "2500.000", "-0.003"},
+		{"floor", RoundFloor, 2.1, 0.001, "0.002", "2100.000", "-0.003"},
+		{"ceil", RoundCeil, 2.1, 0.001, "0.003", "2100.000", "-0.002"},
+		{"div-half-even", RoundHalfEven, 2, 3, "6.000", "0.667", "-6.000"},
+		{"div-down", RoundDown, 2, 3, "6.000", "0.666", "-6.000"},
+	}
+	for _, tc := range tests {
+		d, d1 := NewFromFloat(tc.d), NewFromFloat(tc.d1)
+		if m, err := d.MulRound(d1, tc.mode); err != nil || m.StringFixed(3) != tc.mul {
+			t.Errorf("Error(%s): multiply expected `%s`, got `%s` `%v`", tc.name, tc.mul, m.StringFixed(3), err)
+		}
+		if q, err := d.DivRound(d1, tc.mode); err != nil || q.StringFixed(3) != tc.div {
+			t.Errorf("Error(%s): divide expected `%s`, got `%s` `%v`", tc.name, tc.div, q.StringFixed(3), err)
+		}
+		if m, err := d.Neg().MulRound(d1, tc.mode); err != nil || m.StringFixed(3) != tc.negMul {
+			t.Errorf("Error(%s): negative multiply expected `%s`, got `%s` `%v`", tc.name, tc.negMul, m.StringFixed(3), err)
+		}
+	}
+
+	// price * quantity of a share lot overflows an int64 intermediate
+	price, quantity := NewFromFloat(4321.987), NewFromInt(2500000)
+	if total := price.Mul(quantity); total.StringFixed(3) != "10804967500.000" {
+		t.Errorf("lot total: got `%s`", total.StringFixed(3))
+	}
+	if _, err := NewFromInt(1).DivRound(Zero, RoundHalfEven); err != ErrDivideByZero {
+		t.Errorf("divide by zero: got `%v`", err)
+	}
+	if _, err := NewFromInt(1e13).CheckedDiv(NewFromFloat(0.001)); err != ErrOverflow {
+		t.Errorf("divide overflow: got `%v`", err)
+	}
+}
+
+func TestNumberFormat(t *testing.T) {
+	tests := []struct {
+		example string
+		format  NumberFormat
+		input   string
+		output  string
+	}{
+		{"1234.56", NumberFormat{}, "-1234.5", "-1234.50"},
+		{"1,234.56", NumberFormat{Group: ','}, "-1,234,567.5", "-1,234,567.50"},
+		{"1.234,56", NumberFormat{Decimal: ',', Group: '.'}, "-1.234.567,5", "-1.234.567,50"},
+		{"1234,56", NumberFormat{Decimal: ','}, "1234567,5", "1234567,50"},
+		{"1'234.56", NumberFormat{Group: '\''}, "1'234'567.5", "1'234'567.50"},
+		{"12,34,567.89", NumberFormat{Group: ',', Indian: true}, "-1,23,45,678.9", "-1,23,45,678.90"},
+		{"(1,234.56)", NumberFormat{Group: ',', Parens: true}, "(1,234,567.5)", "(1,234,567.50)"},
+		{"1,234,567", NumberFormat{Group: ','}, "999", "999.00"},
+	}
+	for _, tc := range tests {
+		f, err := ParseNumberFormat(tc.example)
+		if err != nil || f != tc.format {
+			t.Errorf("Error(%s): expected format %+v, got %+v `%v`", tc.example, tc.format, f, err)
+			continue
+		}
+		d, err := f.Parse(tc.input)
+		if err != nil {
+			t.Errorf("Error(%s): unexpected error `%s`", tc.example, err)
+		}
+		var buf [40]byte
+		if out := string(buf[f.Fixed(buf[:], d, 2):]); out != tc.output {
+			t.Errorf("Error(%s): expected `%s`, got `%s`", tc.example, tc.output, out)
+		}
+	}
+
+	for _, example := range []string{"1x", "1,2345.6", "12'34.5", "1.234.56", "1,234.5,6"} {
+		if f, err := ParseNumberFormat(example); err == nil {
+			t.Errorf("Error(%s): expected error, got %+v", example, f)
+		}
+	}
+
+	european := NumberFormat{Decimal: ',', Group: '.'}
+	for _, input := range []string{"1,2,3", ".123", "1.5.", "(5)", "10.50", "0.12345678", "1.23.456", "1234.567,5"} {
+		if _, err := european.Parse(input); err == nil {
+			t.Errorf("Error(%s): expected error", input)
+		}
+	}
+
+	indian := NumberFormat{Group: ',', Indian: true}
+	for _, input := range []string{"1,234,567", "12,3,456", "123,45,678"} {
+		if _, err := indian.Parse(input); err == nil {
+			t.Errorf("Error(%s): expected error", input)
+		}
+	}
+
+	if d, err := european.Evaluate("(1.000,5 * 2)"); err != nil || european.String(d, 2) != "2.001,00" {
+		t.Errorf("evaluate: got `%s`, `%v`", european.String(d, 2), err)
+	}
+	accounting := NumberFormat{Group: ',', Parens: true}
+	if d, err := accounting.Evaluate("(12.00)"); err != nil || accounting.String(d, 2) != "12.00" {
+		t.Errorf("evaluate parentheses: got `%s`, `%v`", accounting.String(d, 2), err)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		expr, result string
+	}{
+		{"12.50*3", "37.50"},
+		{"(12.50 * 3)", "37.50"},
+		{"1 + 2 * 3", "7.00"},
+		{"(1 + 2) * 3", "9.00"},
+		{"-(1 + 2) - -3", "0.00"},
+		{"10 / 4", "2.50"},
+		{"2 / 3", "0.67"},
+		{"-2 / 3", "-0.67"},
+		{"1.005 * 0.5", "0.50"},
+		{"4.35 * 100", "435.00"},
+	}
+	for _, tc := range tests {
+		d, err := Evaluate(tc.expr)
+		if err != nil {
+			t.Fatalf("Error(%s): unexpected error `%s`", tc.expr, err)
+		}
+		if d.StringFixedBank() != tc.result {
+			t.Errorf("Error(%s): expected `%s`, got `%s`", tc.expr, tc.result, d.StringFixedBank())
+		}
+	}
+
+	for expr, msg := range map[string]string{
+		"":                        "unexpected end of expression",
+		"1 +":                     "unexpected end of expression",
+		"(1 + 2":                  "missing )",
+		"1 + 2)":                  "unexpected ')' at position 6",
+		"1 $ 2":                   "unexpected '$' at position 3",
+		"1 / 0":                   "division by zero",
+		"1000000000 * 1000000000": "decimal overflow",
+		"1.2.3*2":                 "invalid syntax",
+	} {
+		if _, err := Evaluate(expr); err == nil || err.Error() != msg {
+			t.Errorf("Error(%s): expected error `%s`, got `%v`", expr, msg, err)
+		}
+	}
+}
+
 var testParseCases = []testCase{
 	{
 		"negzero",
@@ -426,7 +681,7 @@ func BenchmarkStringFixedBank(b *testing.B) {
 	for i := range len(numbers) {
 		numbers[i] = NewFromFloat(rand.Float64() * 100000)
 		if i%2 == 0 {
-			numbers[i] *= -1
+			numbers[i] = numbers[i].Neg()
 		}
 	}
 	for b.Loop() {
@@ -0,0 +1,154 @@
+package decimal
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Evaluate returns the value of an arithmetic expression, such as
+// "(12.50 * 3) - 1", with numbers in the plain format. Numbers are added
+// (+), subtracted (-), multiplied (*), and divided (/), with the usual
+// precedence, grouped by parentheses, and negated by unary minus.
+//
+// Multiplication and division round the result to the nearest value with the
+// digits of the more precise number, with halves rounded away from zero.
+func Evaluate(expr string) (Decimal, error) {
+	return NumberFormat{}.Evaluate(expr)
+}
+
+// Evaluate returns the value of an arithmetic expression, see Evaluate, with
+// numbers in the format. Negative numbers are written with a minus sign, as
+// parentheses group.
+func (f NumberFormat) Evaluate(expr string) (Decimal, error) {
+	f.Parens = false
+	p := exprParser{expr: expr, format: f}
+	d, err := p.parseSum()
+	if err != nil {
+		return Zero, err
+	}
+	if p.skipSpace(); p.pos < len(p.expr) {
+		return Zero, fmt.Errorf("unexpected %q at position %d", p.expr[p.pos], p.pos+1)
+	}
+	return d, nil
+}
+
+// exprParser is a recursive descent parser of an arithmetic expression.
+type exprParser struct {
+	expr   string
+	pos    int
+	format NumberFormat
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.expr) && (p.expr[p.pos] == ' ' || p.expr[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// next returns the next non-space byte, or 0 at the end of the expression.
+func (p *exprParser) next() byte {
+	p.skipSpace()
+	if p.pos < len(p.expr) {
+		return p.expr[p.pos]
+	}
+	return 0
+}
+
+// parseSum parses terms separated by + or -.
+func (p *exprParser) parseSum() (Decimal, error) {
+	d, err := p.parseProduct()
+	if err != nil {
+		return Zero, err
+	}
+	for {
+		op := p.next()
+		if op != '+' && op != '-' {
+			return d, nil
+		}
+		p.pos++
+		d1, err := p.parseProduct()
+		if err != nil {
+			return Zero, err
+		}
+		if op == '+' {
+			d, err = d.CheckedAdd(d1)
+		} else {
+			d, err = d.CheckedSub(d1)
+		}
+		if err != nil {
+			return Zero, err
+		}
+	}
+}
+
+// parseProduct parses factors separated by * or /.
+func (p *exprParser) parseProduct() (Decimal, error) {
+	d, err := p.parseFactor()
+	if err != nil {
+		return Zero, err
+	}
+	for {
+		op := p.next()
+		if op != '*' && op != '/' {
+			return d, nil
+		}
+		p.pos++
+		d1, err := p.parseFactor()
+		if err != nil {
+			return Zero, err
+		}
+		if op == '*' {
+			d, err = d.MulRound(d1, RoundHalfUp)
+		} else {
+			d, err = d.DivRound(d1, RoundHalfUp)
+		}
+		if err != nil {
+			return Zero, err
+		}
+	}
+}
+
+// parseFactor parses a number, a negated factor, or a parenthesised
+// expression.
+func (p *exprParser) parseFactor() (Decimal, error) {
+	switch ch := p.next(); {
+	case ch == 0:
+		return Zero, errors.New("unexpected end of expression")
+	case ch == '-':
+		p.pos++
+		d, err := p.parseFactor()
+		if err != nil {
+			return Zero, err
+		}
+		return Zero.CheckedSub(d)
+	case ch == '(':
+		p.pos++
+		d, err := p.parseSum()
+		if err != nil {
+			return Zero, err
+		}
+		if p.next() != ')' {
+			return Zero, errors.New("missing )")
+		}
+		p.pos++
+		return d, nil
+	case isNumberByte(ch):
+		start := p.pos
+		for p.pos < len(p.expr) && isNumberByte(p.expr[p.pos]) {
+			p.pos++
+		}
+		return p.format.Parse(p.expr[start:p.pos])
+	default:
+		return Zero, fmt.Errorf("unexpected %q at position %d", ch, p.pos+1)
+	}
+}
+
+// isNumberByte returns true for the digits, decimal points, and group
+// separators of a number.
+func isNumberByte(ch byte) bool {
+	switch ch {
+	case '.', ',', '\'':
+		return true
+	}
+	return isDigit(ch)
+}
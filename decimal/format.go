@@ -0,0 +1,227 @@
+package decimal
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// NumberFormat is how numbers are written: the decimal point, the separator
+// between groups of digits of the whole number part, and how negative
+// numbers are marked.
+//
+// The zero value is the plain format, such as "-1234.56".
+type NumberFormat struct {
+	// Decimal is the decimal point, '.' if zero.
+	Decimal byte
+	// Group separates groups of three digits, no groups if zero.
+	Group byte
+	// Indian groups digits in twos above the first group of three, such as
+	// "12,34,567.89".
+	Indian bool
+	// Parens marks negative numbers with parentheses, such as "(12.00)",
+	// instead of a minus sign.
+	Parens bool
+}
+
+// ParseNumberFormat returns the number format of an example number, such as
+// "1,234.56", "1.234,56", "1'234.56", "12,34,567.89" (Indian), or
+// "(1,234.56)" (parentheses for negative numbers). The last separator is the
+// decimal point, unless it also separates groups.
+func ParseNumberFormat(example string) (NumberFormat, error) {
+	var f NumberFormat
+	s := example
+	if len(s) > 2 && s[0] == '(' && s[len(s)-1] == ')' {
+		f.Parens = true
+		s = s[1 : len(s)-1]
+	}
+	s = strings.TrimPrefix(s, "-")
+
+	whole := s
+	if iSep := strings.LastIndexAny(s, ".,'"); iSep >= 0 && strings.IndexByte(s, s[iSep]) == iSep {
+		f.Decimal = s[iSep]
+		whole = s[:iSep]
+		if f.Decimal == '\'' || strings.TrimLeft(s[iSep+1:], "0123456789") != "" {
+			return NumberFormat{}, fmt.Errorf("unable to parse number format(%s)", example)
+		}
+	}
+
+	groups := []string{whole}
+	if iSep := strings.IndexAny(whole, ".,'"); iSep >= 0 {
+		f.Group = whole[iSep]
+		groups = strings.Split(whole, whole[iSep:iSep+1])
+	}
+	if f.Group == f.Decimal && f.Group != 0 {
+		return NumberFormat{}, fmt.Errorf("unable to parse number format(%s)", example)
+	}
+	for i, group := range groups {
+		size := 3
+		if i == len(groups)-2 && len(group) == 2 {
+			f.Indian = true
+		}
+		if f.Indian && i < len(groups)-1 {
+			size = 2
+		}
+		if i == 0 {
+			size = min(size, len(group))
+		}
+		if len(groups) > 1 && (len(group) != size || len(group) == 0) || strings.TrimLeft(group, "0123456789") != "" {
+			return NumberFormat{}, fmt.Errorf("unable to parse number format(%s)", example)
+		}
+	}
+
+	if f.Decimal == '.' {
+		f.Decimal = 0
+	}
+	return f, nil
+}
+
+// DecimalPoint returns the decimal point of the format.
+func (f NumberFormat) DecimalPoint() byte {
+	if f.Decimal == 0 {
+		return '.'
+	}
+	return f.Decimal
+}
+
+// Parse returns a Decimal from a string representation in the plain format,
+// such as "-1234.56". Unlike NewFromString, the Decimal keeps every digit
+// after the decimal point, up to 9.
+func Parse(s string) (Decimal, error) {
+	return NumberFormat{}.Parse(s)
+}
+
+// Parse returns a Decimal from a string representation in the format. Group
+// separators are optional, but must separate groups of the size of the
+// format. The Decimal keeps every digit after the decimal point, up to 9.
+func (f NumberFormat) Parse(s string) (Decimal, error) {
+	if f == (NumberFormat{}) {
+		return newFromString(s, maxPrecision)
+	}
+
+	neg := false
+	if f.Parens && len(s) > 2 && s[0] == '(' && s[len(s)-1] == ')' {
+		neg = true
+		s = s[1 : len(s)-1]
+		if strings.HasPrefix(s, "-") {
+			return Zero, errInvalid
+		}
+	}
+
+	var buf [32]byte
+	plain := buf[:0]
+	seenPoint := false
+	for i := range len(s) {
+		switch ch := s[i]; {
+		case ch == f.DecimalPoint():
+			plain = append(plain, '.')
+			seenPoint = true
+		case f.Group != 0 && ch == f.Group:
+			// separates digits of the whole number part
+			if seenPoint || i == 0 || i == len(s)-1 || !isDigit(s[i-1]) || !isDigit(s[i+1]) {
+				return Zero, errInvalid
+			}
+		case ch == '.':
+			return Zero, errInvalid
+		default:
+			plain = append(plain, ch)
+		}
+	}
+
+	if f.Group != 0 && !f.validGroups(s) {
+		return Zero, errInvalid
+	}
+
+	d, err := newFromString(string(plain), maxPrecision)
+	if neg {
+		d = d.Neg()
+	}
+	return d, err
+}
+
+// validGroups returns true if the group separators of s, if any, separate
+// groups of three digits, or two digits above the first group of three for
+// Indian groups, with up to that many digits in the leading group.
+func (f NumberFormat) validGroups(s string) bool {
+	whole, _, _ := strings.Cut(s, string(f.DecimalPoint()))
+	groups := strings.Split(strings.TrimPrefix(whole, "-"), string(f.Group))
+	if len(groups) == 1 {
+		return true
+	}
+	for i, group := range groups {
+		size := 3
+		if f.Indian && i < len(groups)-1 {
+			size = 2
+		}
+		if i == 0 && len(group) > 0 && len(group) <= size {
+			continue
+		}
+		if len(group) != size {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// String returns a banker rounded fixed-point string of d with places
+// digits after the decimal point, in the format.
+func (f NumberFormat) String(d Decimal, places int) string {
+	var buf [40]byte
+	n := f.Fixed(buf[:], d, places)
+	return string(buf[n:])
+}
+
+// Fixed writes a banker rounded fixed-point string of d with places digits
+// after the decimal point, up to 9, in the format to the tail of the passed
+// in byte array. The byte array needs room for 40 bytes.
+func (f NumberFormat) Fixed(buf []byte, d Decimal, places int) (n int) {
+	var plainBuf [32]byte
+	plain := plainBuf[d.Fixed(plainBuf[:], places):]
+	neg := d.value < 0
+	if neg {
+		plain = plain[1:]
+	}
+
+	w := len(buf)
+	if neg && f.Parens {
+		w--
+		buf[w] = ')'
+	}
+
+	whole := plain
+	if iPoint := bytes.IndexByte(plain, '.'); iPoint >= 0 {
+		whole = plain[:iPoint]
+		w -= len(plain) - iPoint
+		copy(buf[w:], plain[iPoint:])
+		buf[w] = f.DecimalPoint()
+	}
+
+	size, count := 3, 0
+	for i := len(whole) - 1; i >= 0; i-- {
+		if f.Group != 0 && count == size {
+			w--
+			buf[w] = f.Group
+			count = 0
+			if f.Indian {
+				size = 2
+			}
+		}
+		w--
+		buf[w] = whole[i]
+		count++
+	}
+
+	if neg {
+		w--
+		if f.Parens {
+			buf[w] = '('
+		} else {
+			buf[w] = '-'
+		}
+	}
+	return w
+}
@@ -0,0 +1,75 @@
+package decimal
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// ErrDivideByZero is returned when dividing by zero.
+var ErrDivideByZero = errors.New("division by zero")
+
+// RoundingMode is how the result of an operation is rounded to the digits
+// kept by the result.
+type RoundingMode int
+
+// Rounding modes, for the digits beyond those kept.
+const (
+	// RoundDown truncates toward zero.
+	RoundDown RoundingMode = iota
+	// RoundHalfEven rounds to the nearest, with halves rounded to even
+	// (banker's rounding).
+	RoundHalfEven
+	// RoundHalfUp rounds to the nearest, with halves rounded away from zero.
+	RoundHalfUp
+	// RoundFloor rounds toward negative infinity.
+	RoundFloor
+	// RoundCeil rounds toward positive infinity.
+	RoundCeil
+)
+
+// MulRound returns d * d1 rounded with mode, or ErrOverflow if the product
+// does not fit in a Decimal. The intermediate product is 128 bits, so it is
+// exact.
+func (d Decimal) MulRound(d1 Decimal, mode RoundingMode) (Decimal, error) {
+	scale, scale1 := d.scale(), d1.scale()
+	hi, lo := bits.Mul64(d.abs(), d1.abs())
+	return quoRound(hi, lo, pow10[min(scale, scale1)], (d.value < 0) != (d1.value < 0), mode, max(scale, scale1))
+}
+
+// DivRound returns d / d1 rounded with mode, ErrDivideByZero if d1 is zero,
+// or ErrOverflow if the quotient does not fit in a Decimal.
+func (d Decimal) DivRound(d1 Decimal, mode RoundingMode) (Decimal, error) {
+	if d1.IsZero() {
+		return Zero, ErrDivideByZero
+	}
+	scale, scale1 := d.scale(), d1.scale()
+	quoScale := max(scale, scale1)
+	hi, lo := bits.Mul64(d.abs(), pow10[quoScale-scale+scale1])
+	return quoRound(hi, lo, d1.abs(), (d.value < 0) != (d1.value < 0), mode, quoScale)
+}
+
+// quoRound returns the 128-bit hi, lo divided by m, rounded with mode, with
+// the sign at the scale.
+func quoRound(hi, lo, m uint64, neg bool, mode RoundingMode, scale int) (Decimal, error) {
+	if hi >= m {
+		return Zero, ErrOverflow
+	}
+	q, r := bits.Div64(hi, lo, m)
+	if r > 0 {
+		var up bool
+		switch mode {
+		case RoundHalfEven:
+			up = r > m-r || (r == m-r && q%2 != 0)
+		case RoundHalfUp:
+			up = r >= m-r
+		case RoundFloor:
+			up = neg
+		case RoundCeil:
+			up = !neg
+		}
+		if up {
+			q++
+		}
+	}
+	return fromAbs(q, neg, scale)
+}
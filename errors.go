@@ -0,0 +1,94 @@
+package ledger
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrorKind is the kind of problem found in a ledger file.
+type ErrorKind int
+
+// Kinds of parse errors. Undeclared accounts and commodities are only
+// reported when parsing with WithStrict.
+const (
+	TransactionError ErrorKind = iota
+	AutomatedTransactionError
+	PeriodicTransactionError
+	PriceError
+	IncludeError
+	UndeclaredAccountError
+	UndeclaredCommodityError
+	BalanceAssertionError
+)
+
+var errorKindNames = [...]string{
+	TransactionError:          "transaction",
+	AutomatedTransactionError: "automated-transaction",
+	PeriodicTransactionError:  "periodic-transaction",
+	PriceError:                "price",
+	IncludeError:              "include",
+	UndeclaredAccountError:    "undeclared-account",
+	UndeclaredCommodityError:  "undeclared-commodity",
+	BalanceAssertionError:     "balance-assertion",
+}
+
+// String returns the name of the kind, such as "transaction".
+func (k ErrorKind) String() string {
+	if k < 0 || int(k) >= len(errorKindNames) {
+		return fmt.Sprintf("ErrorKind(%d)", int(k))
+	}
+	return errorKindNames[k]
+}
+
+// MarshalText returns the name of the kind.
+func (k ErrorKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// ParseError is a problem at a line of a ledger file. Line and Column start
+// at 1, and Column is where the text of the line starts.
+//
+// Parsing carries on with the next transaction or directive after an error,
+// so the error returned by parsing may join many ParseErrors.
+type ParseError struct {
+	File   string
+	Line   int
+	Column int
+	Kind   ErrorKind
+	Err    error
+}
+
+// Error returns the error as FILE:LINE: MESSAGE.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.File, e.Line, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseErrors returns every ParseError within err, which may join many
+// errors, in order.
+func ParseErrors(err error) []*ParseError {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case *ParseError:
+		return []*ParseError{e}
+	case interface{ Unwrap() []error }:
+		var errs []*ParseError
+		for _, inner := range e.Unwrap() {
+			errs = append(errs, ParseErrors(inner)...)
+		}
+		return errs
+	}
+	return nil
+}
+
+// textColumn returns the column where the text of a line starts, after any
+// indent.
+func textColumn(line string) int {
+	return len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace)) + 1
+}
@@ -5,7 +5,6 @@ go 1.26
 toolchain go1.26.0
 
 require (
-	github.com/alfredxing/calc v0.0.0-20180827002445-77daf576f976
 	github.com/andybalholm/brotli v1.0.6
 	github.com/hako/durafmt v0.0.0-20210608085754-5c1018a4e16b
 	github.com/ivanpirog/coloredcobra v1.0.1
@@ -0,0 +1,91 @@
+package ledger
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// includePaths returns the files matched by the pattern of an include
+// directive in the file filename, sorted in lexical order. A relative pattern
+// is relative to the directory of filename, a pattern starting with "~/" is
+// relative to the home directory, and a "**" element of the pattern matches
+// any number of directories.
+func includePaths(filename, pattern string) ([]string, error) {
+	if rest, found := strings.CutPrefix(pattern, "~/"); found || pattern == "~" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		pattern = filepath.Join(home, rest)
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(filepath.Dir(filename), pattern)
+	}
+
+	elems := strings.Split(filepath.ToSlash(pattern), "/")
+	iRecursive := slices.Index(elems, "**")
+	if iRecursive < 0 {
+		return filepath.Glob(pattern)
+	}
+
+	// glob the directories before the first "**", and match the files
+	// within them against the rest of the pattern
+	root := filepath.FromSlash(strings.Join(elems[:iRecursive], "/"))
+	switch {
+	case iRecursive == 0:
+		root = "."
+	case len(root) == 0:
+		root = string(filepath.Separator)
+	}
+	roots, err := filepath.Glob(root)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if matchElems(elems[iRecursive:], strings.Split(filepath.ToSlash(rel), "/")) {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths), nil
+}
+
+// matchElems returns true if the elements of a path match the elements of a
+// pattern, with "**" matching any number of elements.
+func matchElems(pattern, elems []string) bool {
+	if len(pattern) == 0 {
+		return len(elems) == 0
+	}
+	if pattern[0] == "**" {
+		for i := range len(elems) + 1 {
+			if matchElems(pattern[1:], elems[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(elems) == 0 {
+		return false
+	}
+	if matched, _ := filepath.Match(pattern[0], elems[0]); !matched {
+		return false
+	}
+	return matchElems(pattern[1:], elems[1:])
+}
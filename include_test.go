@@ -1,7 +1,11 @@
 package ledger
 
 import (
+	"context"
 	"errors"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -10,7 +14,7 @@ func TestIncludeSimple(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	bals := GetBalances(trans, []string{"Assets"})
+	bals, _ := GetBalances(trans, []string{"Assets"})
 	if bals[0].Balance.StringRound() != "50" {
 		t.Fatal(errors.New("should be 50"))
 	}
@@ -21,7 +25,7 @@ func TestIncludeGlob(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	bals := GetBalances(trans, []string{"Assets"})
+	bals, _ := GetBalances(trans, []string{"Assets"})
 	if bals[0].Balance.StringRound() != "80" {
 		t.Fatal(errors.New("should be 80"))
 	}
@@ -29,7 +33,9 @@ func TestIncludeGlob(t *testing.T) {
 
 func TestIncludeUnbalanced(t *testing.T) {
 	_, err := ParseLedgerFile("testdata/ledgerRootUnbalanced.dat")
-	if err.Error() != "testdata/ledger-2021-05.dat:12: unable to parse transaction: unable to balance transaction: no empty account to place extra balance" {
+	// the file is included twice
+	exp := "testdata/ledger-2021-05.dat:12: unable to parse transaction: unable to balance transaction: no empty account to place extra balance"
+	if err.Error() != exp+"\n"+exp {
 		t.Fatal(err)
 	}
 }
@@ -47,3 +53,107 @@ func TestNonExistant(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestIncludeParseTransactionsOrder(t *testing.T) {
+	var dates []string
+	for trans, err := range ParseTransactionsFile(context.Background(), "testdata/ledgerRootGlob.dat") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		dates = append(dates, trans.Date.Format("2006/01/02"))
+	}
+	exp := []string{
+		"2022/01/01", "2022/01/01", "2022/01/01", "2022/01/01",
+		"2022/02/01", "2022/02/01", "2022/02/01", "2022/02/01",
+		"2022/04/01", "2022/04/01", "2022/04/01", "2022/04/01",
+		"2022/03/01", "2022/03/01",
+	}
+	if !slices.Equal(dates, exp) {
+		t.Errorf("expected %v, got %v", exp, dates)
+	}
+}
+
+func TestIncludeOrder(t *testing.T) {
+	var exp []*Transaction
+	for trans, err := range ParseTransactionsFile(context.Background(), "testdata/ledgerRootGlob.dat") {
+		if err != nil {
+			t.Fatal(err)
+		}
+		exp = append(exp, trans)
+	}
+	for range 10 {
+		trans, err := ParseLedgerFile("testdata/ledgerRootGlob.dat")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.EqualFunc(trans, exp, func(a, b *Transaction) bool {
+			return a.Position == b.Position
+		}) {
+			t.Fatal("included transactions should be in the order they are written")
+		}
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	_, err := ParseLedgerFile("testdata/ledgerRootCycle.dat")
+	if err == nil || err.Error() != "testdata/ledgerCycle.dat:1: unable to include file(ledgerRootCycle.dat): include cycle" {
+		t.Fatal(err)
+	}
+}
+
+func TestIncludeRecursive(t *testing.T) {
+	trans, err := ParseLedgerFile("testdata/ledgerRootRecursive.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payees []string
+	for _, tr := range trans {
+		payees = append(payees, tr.Payee)
+	}
+	if exp := []string{"Opening", "Nested", "Deep", "Top", "After"}; !slices.Equal(payees, exp) {
+		t.Errorf("expected %v, got %v", exp, payees)
+	}
+}
+
+func TestIncludePaths(t *testing.T) {
+	abs, err := filepath.Abs("testdata/ledger-2022-01.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	tests := []struct {
+		pattern string
+		exp     []string
+	}{
+		{"ledger-2022-0[12].dat", []string{"testdata/ledger-2022-01.dat", "testdata/ledger-2022-02.dat"}},
+		{abs, []string{abs}},
+		{"~/main.dat", []string{filepath.Join(home, "main.dat")}},
+		{"recursive/**/*.dat", []string{
+			"testdata/recursive/2022/nested.dat",
+			"testdata/recursive/2022/q1/deep.dat",
+			"testdata/recursive/top.dat",
+		}},
+		{"recursive/**/q1/*.dat", []string{"testdata/recursive/2022/q1/deep.dat"}},
+	}
+	if err := os.WriteFile(filepath.Join(home, "main.dat"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range tests {
+		paths, err := includePaths("testdata/ledgerRoot.dat", tc.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range paths {
+			paths[i] = filepath.ToSlash(paths[i])
+		}
+		for i := range tc.exp {
+			tc.exp[i] = filepath.ToSlash(tc.exp[i])
+		}
+		if !slices.Equal(paths, tc.exp) {
+			t.Errorf("%s: expected %v, got %v", tc.pattern, tc.exp, paths)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"unicode/utf8"
 
 	"github.com/howeyc/ledger"
+	"github.com/howeyc/ledger/decimal"
 	"github.com/spf13/cobra"
 )
 
@@ -27,7 +28,8 @@ func PrintCSV(generalLedger []*ledger.Transaction, filterArr []string) {
 				}
 			}
 			if inFilter {
-				outBalanceString := accChange.Balance.StringFixedBank()
+				var amtBuf [64]byte
+				outBalanceString := formatAmount(amtBuf[:], accChange.Balance, accChange.Commodity)
 				record := []string{trans.Date.Format(transactionDateFormat),
 					trans.Payee,
 					accChange.Name,
@@ -49,7 +51,31 @@ func PrintCSV(generalLedger []*ledger.Transaction, filterArr []string) {
 	}
 }
 
-func PrintBeancount(generalLedger []*ledger.Transaction, filterArr []string) {
+// beancountCurrencies maps common currency symbols to the currency names
+// beancount requires.
+var beancountCurrencies = map[string]string{
+	"":  "USD",
+	"$": "USD",
+	"€": "EUR",
+	"£": "GBP",
+	"¥": "JPY",
+}
+
+// beancountCurrency returns the beancount currency name of commodity.
+func beancountCurrency(commodity string) string {
+	if currency, found := beancountCurrencies[commodity]; found {
+		return currency
+	}
+	return commodity
+}
+
+// beancountNumber returns every digit of d, with at least 2 after the
+// decimal point.
+func beancountNumber(d decimal.Decimal) string {
+	return d.StringFixed(max(d.Places(), 2))
+}
+
+func PrintBeancount(generalLedger []*ledger.Transaction, filterArr []string) error {
 	// no spaces in account names for beancount
 	for i := range generalLedger {
 		for j := range generalLedger[i].AccountChanges {
@@ -58,7 +84,10 @@ func PrintBeancount(generalLedger []*ledger.Transaction, filterArr []string) {
 		}
 	}
 
-	accounts := ledger.GetBalances(generalLedger, filterArr)
+	accounts, err := ledger.GetBalances(generalLedger, filterArr)
+	if err != nil {
+		return err
+	}
 
 	var firstDate time.Time
 	if len(generalLedger) > 0 {
@@ -85,13 +114,32 @@ func PrintBeancount(generalLedger []*ledger.Transaction, filterArr []string) {
 			for _, comm := range trans.Comments {
 				fmt.Println(comm)
 			}
-			fmt.Println(trans.Date.Format("2006-01-02"), "*", "\""+trans.Payee+"\"", trans.PayeeComment)
+			flag := "*"
+			if trans.Status == ledger.Pending {
+				flag = "!"
+			}
+			fmt.Println(trans.Date.Format("2006-01-02"), flag, "\""+trans.Payee+"\"", trans.PayeeComment)
 			for _, acc := range trans.AccountChanges {
-				fmt.Println("   ", acc.Name, "        ", acc.Balance.StringFixedBank(), "USD", acc.Comment)
+				var costStr string
+				if cost := acc.Cost; cost != nil {
+					if cost.HasLot() {
+						costStr += fmt.Sprintf(" {%s %s}", beancountNumber(cost.LotPrice), beancountCurrency(cost.LotCommodity))
+					}
+					total, terr := cost.Price.CheckedMul(acc.Balance)
+					switch {
+					case cost.HasLot() && cost.Price.Cmp(cost.LotPrice) == 0 && cost.Commodity == cost.LotCommodity:
+					case terr == nil && total.Cmp(cost.Total) == 0:
+						costStr += fmt.Sprintf(" @ %s %s", beancountNumber(cost.Price), beancountCurrency(cost.Commodity))
+					default:
+						costStr += fmt.Sprintf(" @@ %s %s", beancountNumber(cost.Total.Abs()), beancountCurrency(cost.Commodity))
+					}
+				}
+				fmt.Println("   ", acc.Name, "        ", beancountNumber(acc.Balance), beancountCurrency(acc.Commodity)+costStr, acc.Comment)
 			}
 			fmt.Println()
 		}
 	}
+	return nil
 }
 
 var exportType string
@@ -110,7 +158,9 @@ var exportCmd = &cobra.Command{
 		case "csv":
 			PrintCSV(generalLedger, args)
 		case "beancount":
-			PrintBeancount(generalLedger, args)
+			if err := PrintBeancount(generalLedger, args); err != nil {
+				log.Fatalln(err)
+			}
 		default:
 			fmt.Fprintln(os.Stderr, "unknown export type specified")
 		}
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/howeyc/ledger"
 	"github.com/patrickmn/go-cache"
 	"golang.org/x/time/rate"
 )
@@ -152,3 +153,23 @@ func fundAnnualDividends(symbol string) float64 {
 
 	return 0
 }
+
+// journalQuote returns the latest price of symbol from the P directives in
+// the journal, and the price before it in the same commodity as the previous
+// close.
+func journalQuote(prices ledger.PriceHistory, symbol string) (last, previousClose float64, err error) {
+	history := prices.Prices(symbol)
+	if len(history) < 1 {
+		return 0, 0, errors.New("Unable to find price in journal for symbol " + symbol)
+	}
+	latest := history[len(history)-1]
+	last, _ = latest.Amount.Float64()
+	previousClose = last
+	for i := len(history) - 2; i >= 0; i-- {
+		if history[i].PriceCommodity == latest.PriceCommodity {
+			previousClose, _ = history[i].Amount.Float64()
+			break
+		}
+	}
+	return last, previousClose, nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/howeyc/ledger"
+	"github.com/spf13/cobra"
+)
+
+var fmtWrite bool
+
+// fmtCmd represents the fmt command
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [file]...",
+	Short: "Align amounts of postings, keeping everything else as written",
+	Run: func(_ *cobra.Command, args []string) {
+		if len(args) == 0 {
+			args = []string{ledgerFilePath}
+		}
+		for _, filename := range args {
+			if err := formatFile(filename); err != nil {
+				log.Fatalln(err)
+			}
+		}
+	},
+}
+
+// formatFile formats a ledger file, writing the result to stdout, or back to
+// the file with --write. Included files are not formatted.
+func formatFile(filename string) error {
+	in, perm := os.Stdin, os.FileMode(0o644)
+	if filename != "-" {
+		ifile, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer ifile.Close()
+		if fi, serr := ifile.Stat(); serr == nil {
+			perm = fi.Mode().Perm()
+		}
+		in = ifile
+	}
+
+	tree, err := ledger.ParseSyntaxTree(in)
+	if err != nil {
+		return err
+	}
+	tree.Format(columnWidth)
+
+	if !fmtWrite || filename == "-" {
+		_, err = tree.WriteTo(os.Stdout)
+		return err
+	}
+
+	// write next to the file and rename, so the file is never left half
+	// written
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tree.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+func init() {
+	rootCmd.AddCommand(fmtCmd)
+
+	fmtCmd.Flags().IntVar(&columnWidth, "columns", 80, "Set the column amounts end at.")
+	fmtCmd.Flags().BoolVarP(&fmtWrite, "write", "w", false, "Write the result back to the file, instead of stdout.")
+}
@@ -28,8 +28,11 @@ var allowMatching bool
 var fieldDelimiter string
 var scaleFactor float64
 
-func trainClassifier(generalLedger []*ledger.Transaction, matchingAccount string) *bayesian.Classifier {
-	allAccounts := ledger.GetBalances(generalLedger, []string{})
+func trainClassifier(generalLedger []*ledger.Transaction, matchingAccount string) (*bayesian.Classifier, error) {
+	allAccounts, err := ledger.GetBalances(generalLedger, []string{})
+	if err != nil {
+		return nil, err
+	}
 	classes := make([]bayesian.Class, len(allAccounts))
 	for i, bal := range allAccounts {
 		classes[i] = bayesian.Class(bal.Name)
@@ -54,7 +57,7 @@ func trainClassifier(generalLedger []*ledger.Transaction, matchingAccount string
 		}
 	}
 
-	return classifier
+	return classifier, nil
 }
 
 func predictAccount(classifier *bayesian.Classifier, inputPayeeWords []string) string {
@@ -83,7 +86,10 @@ func predictAccount(classifier *bayesian.Classifier, inputPayeeWords []string) s
 
 func findMatchingAccount(generalLedger []*ledger.Transaction, accountSubstring string) (string, error) {
 	var matchingAccount string
-	matchingAccounts := ledger.GetBalances(generalLedger, []string{accountSubstring})
+	matchingAccounts, err := ledger.GetBalances(generalLedger, []string{accountSubstring})
+	if err != nil {
+		return "", err
+	}
 	if len(matchingAccounts) < 1 {
 		return "", ErrNoMatchingAccount
 	}
@@ -110,7 +116,7 @@ func importCSV(accountSubstring, csvFileName string) {
 	}
 	defer csvFileReader.Close()
 
-	generalLedger, parseError := ledger.ParseLedgerFile(ledgerFilePath)
+	generalLedger, parseError := ledger.ParseLedgerFile(ledgerFilePath, parseOptions()...)
 	if parseError != nil {
 		fmt.Printf("%s:%s\n", ledgerFilePath, parseError.Error())
 		return
@@ -130,7 +136,11 @@ func importCSV(accountSubstring, csvFileName string) {
 		return
 	}
 
-	classifier := trainClassifier(generalLedger, matchingAccount)
+	classifier, err := trainClassifier(generalLedger, matchingAccount)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	// Find columns from header
 	var dateColumn, payeeColumn, amountColumn, commentColumn int
@@ -168,7 +178,7 @@ func importCSV(accountSubstring, csvFileName string) {
 			expenseAccount.Name = predictAccount(classifier, inputPayeeWords)
 
 			// Parse error, set to zero
-			if dec, derr := decimal.NewFromString(record[amountColumn]); derr != nil {
+			if dec, derr := numberFormat.Parse(record[amountColumn]); derr != nil {
 				expenseAccount.Balance = decimal.Zero
 			} else {
 				expenseAccount.Balance = dec
@@ -180,7 +190,12 @@ func importCSV(accountSubstring, csvFileName string) {
 			}
 
 			// Apply scale
-			expenseAccount.Balance = expenseAccount.Balance.Mul(decScale)
+			scaled, serr := expenseAccount.Balance.CheckedMul(decScale)
+			if serr != nil {
+				fmt.Println("Unable to scale amount:", serr)
+				continue
+			}
+			expenseAccount.Balance = scaled
 
 			// Csv amount is the negative of the expense amount
 			csvAccount.Balance = expenseAccount.Balance.Neg()
@@ -208,7 +223,7 @@ func importCamt(accountSubstring, camtFileName string) {
 	}
 	defer fileReader.Close()
 
-	generalLedger, parseError := ledger.ParseLedgerFile(ledgerFilePath)
+	generalLedger, parseError := ledger.ParseLedgerFile(ledgerFilePath, parseOptions()...)
 	if parseError != nil {
 		fmt.Printf("%s:%s\n", ledgerFilePath, parseError.Error())
 		return
@@ -220,7 +235,11 @@ func importCamt(accountSubstring, camtFileName string) {
 		return
 	}
 
-	classifier := trainClassifier(generalLedger, matchingAccount)
+	classifier, err := trainClassifier(generalLedger, matchingAccount)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	entries, err := camt.ParseCamt(fileReader)
 	if err != nil {
@@ -269,7 +288,12 @@ func importCamt(accountSubstring, camtFileName string) {
 		}
 
 		// Apply scale
-		expenseAccount.Balance = expenseAccount.Balance.Mul(decScale)
+		scaled, serr := expenseAccount.Balance.CheckedMul(decScale)
+		if serr != nil {
+			fmt.Println("Unable to scale amount:", serr)
+			continue
+		}
+		expenseAccount.Balance = scaled
 
 		// Csv amount is the negative of the expense amount
 		camtAccount.Balance = expenseAccount.Balance.Neg()
@@ -296,7 +320,7 @@ func importQFX(accountSubstring, qfxFileName string) {
 	}
 	defer fileReader.Close()
 
-	generalLedger, parseError := ledger.ParseLedgerFile(ledgerFilePath)
+	generalLedger, parseError := ledger.ParseLedgerFile(ledgerFilePath, parseOptions()...)
 	if parseError != nil {
 		fmt.Printf("%s:%s\n", ledgerFilePath, parseError.Error())
 		return
@@ -308,7 +332,11 @@ func importQFX(accountSubstring, qfxFileName string) {
 		return
 	}
 
-	classifier := trainClassifier(generalLedger, matchingAccount)
+	classifier, err := trainClassifier(generalLedger, matchingAccount)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
 	entries, err := qfx.ParseQFX(fileReader)
 	if err != nil {
@@ -345,7 +373,12 @@ func importQFX(accountSubstring, qfxFileName string) {
 		expenseAccount.Balance = amount
 
 		// Apply scale
-		expenseAccount.Balance = expenseAccount.Balance.Mul(decScale)
+		scaled, serr := expenseAccount.Balance.CheckedMul(decScale)
+		if serr != nil {
+			fmt.Println("Unable to scale amount:", serr)
+			continue
+		}
+		expenseAccount.Balance = scaled
 
 		// Account side is the opposite of expense
 		qfxAccount.Balance = expenseAccount.Balance.Neg()
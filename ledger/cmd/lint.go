@@ -1,24 +1,85 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"os"
 
 	"github.com/howeyc/ledger"
 	"github.com/spf13/cobra"
 )
 
+var lintStrict bool
+var lintJSON bool
+
 // lintCmd represents the lint command
 var lintCmd = &cobra.Command{
 	Use:   "lint",
 	Short: "Check ledger for errors",
 	Run: func(_ *cobra.Command, _ []string) {
-		_, lerr := ledger.ParseLedgerFile(ledgerFilePath)
-		if lerr != nil {
+		opts := parseOptions()
+		if lintStrict {
+			opts = append(opts, ledger.WithStrict())
+		}
+		_, lerr := ledger.ParseLedgerFile(ledgerFilePath, opts...)
+		if lintJSON {
+			if err := writeLintJSON(lerr); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		}
+		if lerr == nil {
+			return
+		}
+		perrs := ledger.ParseErrors(lerr)
+		if len(perrs) == 0 {
 			fmt.Println("Ledger: ", lerr)
+			return
+		}
+		for _, perr := range perrs {
+			fmt.Println("Ledger: ", perr)
 		}
 	},
 }
 
+// lintError is a parse error as written by lint --json.
+type lintError struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Kind    string `json:"kind,omitempty"`
+	Message string `json:"message"`
+}
+
+// writeLintJSON writes the parse errors within lerr to stdout as a JSON
+// array. An error without a position, such as a missing ledger file, has no
+// kind or position.
+func writeLintJSON(lerr error) error {
+	lintErrs := []lintError{}
+	for _, perr := range ledger.ParseErrors(lerr) {
+		lintErrs = append(lintErrs, lintError{
+			File:    perr.File,
+			Line:    perr.Line,
+			Column:  perr.Column,
+			Kind:    perr.Kind.String(),
+			Message: perr.Err.Error(),
+		})
+	}
+	var perr *ledger.ParseError
+	if lerr != nil && !errors.As(lerr, &perr) {
+		lintErrs = append(lintErrs, lintError{Message: lerr.Error()})
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(lintErrs)
+}
+
 func init() {
 	rootCmd.AddCommand(lintCmd)
+
+	lintCmd.Flags().BoolVar(&lintStrict, "strict", false, "Report postings to undeclared accounts and commodities")
+	lintCmd.Flags().BoolVar(&lintJSON, "json", false, "Write errors as a JSON array")
 }
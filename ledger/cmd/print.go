@@ -9,6 +9,7 @@ import (
 	"os"
 	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 	"unicode/utf8"
 	"unsafe"
@@ -40,15 +41,125 @@ func formatDate(p []byte, t time.Time) {
 	p[9] = byte(d%10) + '0'
 }
 
+// formatAmount writes bal, along with the commodity symbol, to the tail of
+// buf and returns the written portion as a string. Every digit of bal is
+// written, with at least the places of the commodity format, or 2.
+func formatAmount(buf []byte, bal decimal.Decimal, commodity string) string {
+	return formatAmountIn(numberFormat, buf, bal, commodity)
+}
+
+// formatJournalAmount is formatAmount for amounts written to a journal, which
+// are negated with a minus sign to be read back the same.
+func formatJournalAmount(buf []byte, bal decimal.Decimal, commodity string) string {
+	f := numberFormat
+	f.Parens = false
+	return formatAmountIn(f, buf, bal, commodity)
+}
+
+// formatAmountIn is formatAmount in the number format f.
+func formatAmountIn(f decimal.NumberFormat, buf []byte, bal decimal.Decimal, commodity string) string {
+	if len(commodity) > len(buf)-40 {
+		return formatAmountIn(f, make([]byte, len(commodity)+40), bal, commodity)
+	}
+
+	w := len(buf)
+	prefix := ledger.CommodityIsPrefix(commodity)
+	if !prefix && len(commodity) > 0 {
+		w -= len(commodity)
+		copy(buf[w:], commodity)
+		w--
+		buf[w] = ' '
+	}
+	places := 2
+	if declared := commodityPlaces.Load(); declared != nil {
+		if p, found := (*declared)[commodity]; found {
+			places = p
+		}
+	}
+	n := f.Fixed(buf[:w], bal, max(places, bal.Places()))
+	if prefix {
+		n -= len(commodity)
+		copy(buf[n:], commodity)
+	}
+	return unsafe.String(unsafe.SliceData(buf[n:]), len(buf)-n)
+}
+
+// writeCost writes the lot cost and price annotations of a posting. The
+// total price is written when the per-unit price does not exactly give the
+// total (such as when the total was given with @@).
+func writeCost(w io.StringWriter, quantity decimal.Decimal, cost *ledger.Cost) {
+	var amtBuf [64]byte
+	if cost.HasLot() {
+		w.WriteString(" {")
+		w.WriteString(formatJournalAmount(amtBuf[:], cost.LotPrice, cost.LotCommodity))
+		w.WriteString("}")
+		if cost.Price.Cmp(cost.LotPrice) == 0 && cost.Commodity == cost.LotCommodity {
+			return
+		}
+	}
+	if total, err := cost.Price.CheckedMul(quantity); err == nil && total.Cmp(cost.Total) == 0 {
+		w.WriteString(" @ ")
+		w.WriteString(formatJournalAmount(amtBuf[:], cost.Price, cost.Commodity))
+	} else {
+		w.WriteString(" @@ ")
+		w.WriteString(formatJournalAmount(amtBuf[:], cost.Total.Abs(), cost.Commodity))
+	}
+}
+
+// balanceKey identifies the balance of a single commodity in an account.
+type balanceKey struct {
+	name, commodity string
+}
+
+// amountWidth returns the width of the amount column needed to fit the
+// longest commodity symbol of the accounts.
+func amountWidth(accounts []ledger.Account) int {
+	var commWidth int
+	for _, acc := range accounts {
+		if l := utf8.RuneCountInString(acc.Commodity); l > 0 {
+			commWidth = max(commWidth, l+1)
+		}
+	}
+	return 10 + commWidth
+}
+
 var startString, endString string
 var columnWidth, transactionDepth int
 var showEmptyAccounts bool
 var columnWide bool
 var period string
 var payeeFilter string
+var marketValue bool
+var exchangeCommodity string
+var statusCleared, statusPending, statusUncleared bool
+var tagFilters []string
+var realOnly bool
+var effectiveDates bool
+var showOrigin bool
+var commodityPlaces atomic.Pointer[map[string]int]
+var numberFormat decimal.NumberFormat
+var forecastString string
 var spaceStr string
 
-func cliTransactions(cmd *cobra.Command) ([]*ledger.Transaction, error) {
+// setCommodityPlaces keeps the places of the commodity formats declared in
+// the journal, to write amounts with.
+func setCommodityPlaces(journal *ledger.Journal) {
+	places := make(map[string]int, len(journal.Commodities))
+	for _, decl := range journal.Commodities {
+		if p, found := decl.Places(numberFormat); found {
+			places[decl.Name] = p
+		}
+	}
+	commodityPlaces.Store(&places)
+}
+
+// parseOptions returns the options to parse the journal with, which read
+// amounts in the number format.
+func parseOptions() []ledger.ParseOption {
+	return []ledger.ParseOption{ledger.WithNumberFormat(numberFormat)}
+}
+
+func cliJournal(cmd *cobra.Command) (*ledger.Journal, error) {
 	if columnWidth == 80 && columnWide {
 		columnWidth = 132
 		fd := int(os.Stdout.Fd())
@@ -63,16 +174,35 @@ func cliTransactions(cmd *cobra.Command) ([]*ledger.Transaction, error) {
 	filterByDate := cmd.Flags().Changed("begin-date") || cmd.Flags().Changed("end-date")
 	filterByPayee := cmd.Flags().Changed("payee")
 
-	var generalLedger []*ledger.Transaction
+	var journal *ledger.Journal
 	var parseError error
 	if ledgerFilePath == "-" {
-		generalLedger, parseError = ledger.ParseLedger(os.Stdin)
+		journal, parseError = ledger.ParseJournal(os.Stdin, parseOptions()...)
 	} else {
-		generalLedger, parseError = ledger.ParseLedgerFile(ledgerFilePath)
+		journal, parseError = ledger.ParseJournalFile(ledgerFilePath, parseOptions()...)
 	}
 	if parseError != nil {
 		return nil, parseError
 	}
+	generalLedger := journal.Transactions
+
+	setCommodityPlaces(journal)
+
+	if len(forecastString) > 0 {
+		forecastEnd, ferr := date.Parse(forecastString)
+		if ferr != nil {
+			return nil, errors.New("unable to parse forecast date string argument")
+		}
+
+		// forecast from today, including the forecast end date
+		now := time.Now()
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		generalLedger = append(generalLedger, ledger.Forecast(journal.Periodic, today, forecastEnd.AddDate(0, 0, 1))...)
+	}
+
+	if effectiveDates {
+		generalLedger = effectiveTransactions(generalLedger)
+	}
 
 	slices.SortStableFunc(generalLedger, func(a, b *ledger.Transaction) int {
 		return a.Date.Compare(b.Date)
@@ -103,7 +233,128 @@ func cliTransactions(cmd *cobra.Command) ([]*ledger.Transaction, error) {
 		}
 	}
 
-	return generalLedger, nil
+	if statusCleared || statusPending || statusUncleared {
+		generalLedger = filterPostings(generalLedger, statusSelected)
+	}
+
+	if len(tagFilters) > 0 {
+		generalLedger = filterPostings(generalLedger, tagSelected)
+	}
+
+	if realOnly {
+		generalLedger = filterPostings(generalLedger, realSelected)
+	}
+
+	journal.Transactions = generalLedger
+	return journal, nil
+}
+
+func cliTransactions(cmd *cobra.Command) ([]*ledger.Transaction, error) {
+	journal, err := cliJournal(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return journal.Transactions, nil
+}
+
+// reportDate is the end date of transaction processing, if specified as an
+// argument, otherwise now.
+func reportDate(cmd *cobra.Command) time.Time {
+	if cmd.Flags().Changed("end-date") {
+		if parsedEndDate, err := date.Parse(endString); err == nil {
+			return parsedEndDate.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+	return time.Now()
+}
+
+// filterPostings returns copies of the transactions with only the postings
+// selected by keep. Transactions without any selected postings are removed.
+func filterPostings(generalLedger []*ledger.Transaction, keep func(trans *ledger.Transaction, accChange *ledger.Account) bool) []*ledger.Transaction {
+	results := make([]*ledger.Transaction, 0, len(generalLedger))
+	for _, trans := range generalLedger {
+		ftrans := *trans
+		ftrans.AccountChanges = nil
+		for _, accChange := range trans.AccountChanges {
+			if keep(trans, &accChange) {
+				ftrans.AccountChanges = append(ftrans.AccountChanges, accChange)
+			}
+		}
+		if len(ftrans.AccountChanges) > 0 {
+			results = append(results, &ftrans)
+		}
+	}
+	return results
+}
+
+// statusSelected returns true if the posting has a status selected by the
+// status flags.
+func statusSelected(trans *ledger.Transaction, accChange *ledger.Account) bool {
+	switch trans.PostingStatus(accChange) {
+	case ledger.Cleared:
+		return statusCleared
+	case ledger.Pending:
+		return statusPending
+	}
+	return statusUncleared
+}
+
+// tagSelected returns true if the posting, or its transaction, has every tag
+// of the tag flags.
+func tagSelected(trans *ledger.Transaction, accChange *ledger.Account) bool {
+	for _, tag := range tagFilters {
+		if !trans.PostingHasTag(accChange, tag) {
+			return false
+		}
+	}
+	return true
+}
+
+// effectiveTransactions returns copies of the transactions dated by the
+// effective date of their postings. A transaction with postings on more than
+// one effective date is split into a copy for each date.
+func effectiveTransactions(generalLedger []*ledger.Transaction) []*ledger.Transaction {
+	results := make([]*ledger.Transaction, 0, len(generalLedger))
+	for _, trans := range generalLedger {
+		start := len(results)
+		for _, accChange := range trans.AccountChanges {
+			effectiveDate := trans.PostingEffectiveDate(&accChange)
+			idx := slices.IndexFunc(results[start:], func(etrans *ledger.Transaction) bool {
+				return etrans.Date.Equal(effectiveDate)
+			})
+			if idx < 0 {
+				etrans := *trans
+				etrans.Date = effectiveDate
+				etrans.AccountChanges = nil
+				results = append(results, &etrans)
+				idx = len(results) - 1 - start
+			}
+			results[start+idx].AccountChanges = append(results[start+idx].AccountChanges, accChange)
+		}
+	}
+	return results
+}
+
+// realSelected returns true if the posting is not a virtual posting.
+func realSelected(_ *ledger.Transaction, accChange *ledger.Account) bool {
+	return accChange.Kind == ledger.RealPosting
+}
+
+// marketTransactions returns copies of the transactions with the amount of
+// each posting valued in the target commodity at date.
+func marketTransactions(generalLedger []*ledger.Transaction, prices ledger.PriceHistory, target string, date time.Time) []*ledger.Transaction {
+	results := make([]*ledger.Transaction, 0, len(generalLedger))
+	for _, trans := range generalLedger {
+		mtrans := *trans
+		mtrans.AccountChanges = make([]ledger.Account, len(trans.AccountChanges))
+		for i, accChange := range trans.AccountChanges {
+			accChange.Balance, accChange.Commodity, _ = prices.Value(accChange.Balance, accChange.Commodity, target, date)
+			accChange.Cost = nil
+			mtrans.AccountChanges[i] = accChange
+		}
+		results = append(results, &mtrans)
+	}
+	return results
 }
 
 // printCmd represents the print command
@@ -131,56 +382,109 @@ func init() {
 	printCmd.Flags().StringVar(&payeeFilter, "payee", "", "Filter output to payees that contain this string.")
 	printCmd.Flags().IntVar(&columnWidth, "columns", 80, "Set a column width for output.")
 	printCmd.Flags().BoolVar(&columnWide, "wide", false, "Wide output (use terminal width).")
+	printCmd.Flags().BoolVar(&showOrigin, "origin", false, "Print the file and lines of each transaction as a comment.")
 }
 
 // PrintBalances prints out account balances formatted to a window set to a width of columns.
 // Only shows accounts with names less than or equal to the given depth.
-func PrintBalances(accountList []*ledger.Account, printZeroBalances bool, depth, columns int) {
-	// Calculate widths: 10 columns for balance, rest for accountname
-	if columns < 12 {
-		columns = 12
+func PrintBalances(accountList []*ledger.Account, printZeroBalances bool, depth, columns int) error {
+	var overallBalances []ledger.Account
+	for _, account := range accountList {
+		if strings.Count(account.Name, ":") == 0 {
+			idx := slices.IndexFunc(overallBalances, func(a ledger.Account) bool {
+				return a.Commodity == account.Commodity
+			})
+			if idx < 0 {
+				overallBalances = append(overallBalances, ledger.Account{Commodity: account.Commodity})
+				idx = len(overallBalances) - 1
+			}
+			sum, err := overallBalances[idx].Balance.CheckedAdd(account.Balance)
+			if err != nil {
+				return fmt.Errorf("total balance: %w", err)
+			}
+			overallBalances[idx].Balance = sum
+		}
+	}
+	if len(overallBalances) == 0 {
+		overallBalances = append(overallBalances, ledger.Account{})
+	}
+	slices.SortFunc(overallBalances, func(a, b ledger.Account) int {
+		return strings.Compare(a.Commodity, b.Commodity)
+	})
+
+	// Calculate widths: balance column fits amount and commodity, rest for accountname
+	amtWidth := amountWidth(overallBalances)
+	if columns < amtWidth+2 {
+		columns = amtWidth + 2
 		fmt.Fprintf(os.Stderr, "warning: `columns` too small, setting to %d\n", columns)
 	}
-	accWidth := columns - 11
+	accWidth := columns - amtWidth - 1
 
 	colorNeg := fastcolor.FgRed
 	colorAccount := fastcolor.FgBlue
 	colorReset := fastcolor.Reset
 
-	var amtBuf [24]byte
+	var amtBuf [64]byte
 
 	buf := bufio.NewWriter(os.Stdout)
-	overallBalance := decimal.Zero
+	var prevName string
 	for _, account := range accountList {
 		accDepth := strings.Count(account.Name, ":") + 1
-		if accDepth == 1 {
-			overallBalance = overallBalance.Add(account.Balance)
-		}
 		if (printZeroBalances || account.Balance.Sign() != 0) && (depth < 0 || accDepth <= depth) {
-			n := account.Balance.FixedBank(amtBuf[:])
-			outBalanceString := unsafe.String(unsafe.SliceData(amtBuf[n:]), 24-n)
+			outBalanceString := formatAmount(amtBuf[:], account.Balance, account.Commodity)
 			amtColor := colorReset
 			if account.Balance.Sign() < 0 {
 				amtColor = colorNeg
 			}
-			colorAccount.WriteStringFixed(buf, account.Name, accWidth, false)
+			// Only name the account once when it holds several commodities
+			accName := account.Name
+			if accName == prevName {
+				accName = ""
+			}
+			prevName = account.Name
+			colorAccount.WriteStringFixed(buf, accName, accWidth, false)
 			buf.WriteString(" ")
-			amtColor.WriteStringFixed(buf, outBalanceString, 10, true)
+			amtColor.WriteStringFixed(buf, outBalanceString, amtWidth, true)
 			buf.WriteString(newLine)
 		}
 	}
 	fmt.Fprintln(buf, strings.Repeat("-", columns))
-	n := overallBalance.FixedBank(amtBuf[:])
-	outBalanceString := unsafe.String(unsafe.SliceData(amtBuf[n:]), 24-n)
-	amtColor := colorReset
-	if overallBalance.Sign() < 0 {
-		amtColor = colorNeg
-	}
-	colorAccount.WriteStringFixed(buf, "", accWidth, false)
-	buf.WriteString(" ")
-	amtColor.WriteStringFixed(buf, outBalanceString, 10, true)
-	buf.WriteString(newLine)
-	buf.Flush()
+	for _, overallBalance := range overallBalances {
+		outBalanceString := formatAmount(amtBuf[:], overallBalance.Balance, overallBalance.Commodity)
+		amtColor := colorReset
+		if overallBalance.Balance.Sign() < 0 {
+			amtColor = colorNeg
+		}
+		colorAccount.WriteStringFixed(buf, "", accWidth, false)
+		buf.WriteString(" ")
+		amtColor.WriteStringFixed(buf, outBalanceString, amtWidth, true)
+		buf.WriteString(newLine)
+	}
+	return buf.Flush()
+}
+
+// statusMark returns the mark, followed by a space, written before a payee or
+// account name with the status.
+func statusMark(status ledger.Status) string {
+	switch status {
+	case ledger.Cleared:
+		return "* "
+	case ledger.Pending:
+		return "! "
+	}
+	return ""
+}
+
+// postingBrackets returns the brackets written around the account name of
+// a virtual posting.
+func postingBrackets(kind ledger.PostingKind) (left, right string) {
+	switch kind {
+	case ledger.VirtualPosting:
+		return "(", ")"
+	case ledger.BalancedVirtualPosting:
+		return "[", "]"
+	}
+	return "", ""
 }
 
 // WriteTransaction writes a transaction formatted to fit in specified column width.
@@ -199,28 +503,57 @@ func WriteTransaction(w io.StringWriter, trans *ledger.Transaction, columns int)
 		return strings.Compare(a.Name, b.Name)
 	})
 
-	var amtBuf [24]byte
+	var amtBuf [64]byte
 
 	var dateBuf [10]byte
 	formatDate(dateBuf[:], trans.Date)
 	dateString := unsafe.String(unsafe.SliceData(dateBuf[:]), 10)
 	w.WriteString(dateString)
+	payeeWidth := utf8.RuneCountInString(trans.Payee)
+	if !trans.EffectiveDate.IsZero() {
+		// dateString is reused, after the date is written
+		formatDate(dateBuf[:], trans.EffectiveDate)
+		w.WriteString("=")
+		w.WriteString(dateString)
+		payeeWidth += 11
+	}
 	w.WriteString(spaceStr[:1])
+	if mark := statusMark(trans.Status); len(mark) > 0 {
+		w.WriteString(mark)
+		payeeWidth += len(mark)
+	}
+	if len(trans.Code) > 0 {
+		w.WriteString("(")
+		w.WriteString(trans.Code)
+		w.WriteString(") ")
+		payeeWidth += utf8.RuneCountInString(trans.Code) + 3
+	}
 	w.WriteString(trans.Payee)
 	if len(trans.PayeeComment) > 0 {
-		spaceCount := max(columns-10-utf8.RuneCountInString(trans.Payee), 1)
+		spaceCount := max(columns-10-payeeWidth, 1)
 		w.WriteString(spaceStr[:spaceCount])
 		w.WriteString(trans.PayeeComment)
 	}
 	w.WriteString(newLine)
 	for _, accChange := range trans.AccountChanges {
-		n := accChange.Balance.FixedBank(amtBuf[:])
-		outBalanceString := unsafe.String(unsafe.SliceData(amtBuf[n:]), 24-n)
-		spaceCount := max(columns-4-utf8.RuneCountInString(accChange.Name)-utf8.RuneCountInString(outBalanceString), 1)
+		outBalanceString := formatJournalAmount(amtBuf[:], accChange.Balance, accChange.Commodity)
+		mark := statusMark(accChange.Status)
+		left, right := postingBrackets(accChange.Kind)
+		spaceCount := max(columns-4-len(mark)-len(left)-len(right)-utf8.RuneCountInString(accChange.Name)-utf8.RuneCountInString(outBalanceString), 1)
 		w.WriteString(spaceStr[:4])
+		w.WriteString(mark)
+		w.WriteString(left)
 		w.WriteString(accChange.Name)
+		w.WriteString(right)
 		w.WriteString(spaceStr[:spaceCount])
 		w.WriteString(outBalanceString)
+		if accChange.Cost != nil {
+			writeCost(w, accChange.Balance, accChange.Cost)
+		}
+		if accChange.Assertion != nil {
+			w.WriteString(" = ")
+			w.WriteString(formatJournalAmount(amtBuf[:], accChange.Assertion.Balance, accChange.Assertion.Commodity))
+		}
 		if len(accChange.Comment) > 0 {
 			w.WriteString(spaceStr[:1])
 			w.WriteString(accChange.Comment)
@@ -243,6 +576,11 @@ func PrintLedger(generalLedger []*ledger.Transaction, filterArr []string, column
 			}
 		}
 		if inFilter {
+			if showOrigin && len(trans.Position.Filename) > 0 {
+				buf.WriteString("; ")
+				buf.WriteString(trans.Position.String())
+				buf.WriteString(newLine)
+			}
 			WriteTransaction(buf, trans, columns)
 		}
 	}
@@ -250,15 +588,29 @@ func PrintLedger(generalLedger []*ledger.Transaction, filterArr []string, column
 }
 
 // PrintRegister prints each transaction that matches the given filters.
-func PrintRegister(generalLedger []*ledger.Transaction, filterArr []string, columns int) {
+func PrintRegister(generalLedger []*ledger.Transaction, filterArr []string, columns int) error {
+	// Running total is kept for each commodity, and the total shown is of
+	// the commodity of the posting.
+	var runningBalances []ledger.Account
+	for _, trans := range generalLedger {
+		for _, accChange := range trans.AccountChanges {
+			if !slices.ContainsFunc(runningBalances, func(a ledger.Account) bool {
+				return a.Commodity == accChange.Commodity
+			}) {
+				runningBalances = append(runningBalances, ledger.Account{Commodity: accChange.Commodity})
+			}
+		}
+	}
+
 	// Calculate widths for variable-length part of output
-	// 3 10-width columns (date, account-change, running-total)
+	// 1 10-width column (date), 2 amount columns (account-change, running-total)
 	// 4 spaces
-	if columns < 35 {
-		columns = 35
+	amtWidth := amountWidth(runningBalances)
+	if columns < 15+2*amtWidth {
+		columns = 15 + 2*amtWidth
 		fmt.Fprintf(os.Stderr, "warning: `columns` too small, setting to %d\n", columns)
 	}
-	remainingWidth := columns - (10 * 3) - (4 * 1)
+	remainingWidth := columns - 10 - (amtWidth * 2) - (4 * 1)
 	col1width := remainingWidth / 3
 	col2width := remainingWidth - col1width
 
@@ -267,11 +619,10 @@ func PrintRegister(generalLedger []*ledger.Transaction, filterArr []string, colu
 	colorAccount := fastcolor.FgBlue
 	colorReset := fastcolor.Reset
 
-	var amtBuf [24]byte
+	var amtBuf [64]byte
 	var dateBuf [10]byte
 
 	buf := bufio.NewWriter(os.Stdout)
-	runningBalance := decimal.Zero
 	for _, trans := range generalLedger {
 		for _, accChange := range trans.AccountChanges {
 			inFilter := len(filterArr) == 0
@@ -281,7 +632,15 @@ func PrintRegister(generalLedger []*ledger.Transaction, filterArr []string, colu
 				}
 			}
 			if inFilter {
-				runningBalance = runningBalance.Add(accChange.Balance)
+				runIdx := slices.IndexFunc(runningBalances, func(a ledger.Account) bool {
+					return a.Commodity == accChange.Commodity
+				})
+				runningBalance, err := runningBalances[runIdx].Balance.CheckedAdd(accChange.Balance)
+				if err != nil {
+					buf.Flush()
+					return fmt.Errorf("running balance of %s: %w", accChange.Name, err)
+				}
+				runningBalances[runIdx].Balance = runningBalance
 
 				balamtColor := colorReset
 				if accChange.Balance.Sign() < 0 {
@@ -299,16 +658,14 @@ func PrintRegister(generalLedger []*ledger.Transaction, filterArr []string, colu
 				buf.WriteString(" ")
 				colorAccount.WriteStringFixed(buf, accChange.Name, col2width, false)
 				buf.WriteString(" ")
-				n := accChange.Balance.FixedBank(amtBuf[:])
-				outBalanceString := unsafe.String(unsafe.SliceData(amtBuf[n:]), 24-n)
-				balamtColor.WriteStringFixed(buf, outBalanceString, 10, true)
+				outBalanceString := formatAmount(amtBuf[:], accChange.Balance, accChange.Commodity)
+				balamtColor.WriteStringFixed(buf, outBalanceString, amtWidth, true)
 				buf.WriteString(" ")
-				n = runningBalance.FixedBank(amtBuf[:])
-				outRunningBalanceString := unsafe.String(unsafe.SliceData(amtBuf[n:]), 24-n)
-				runamtColor.WriteStringFixed(buf, outRunningBalanceString, 10, true)
+				outRunningBalanceString := formatAmount(amtBuf[:], runningBalance, accChange.Commodity)
+				runamtColor.WriteStringFixed(buf, outRunningBalanceString, amtWidth, true)
 				buf.WriteString(newLine)
 			}
 		}
 	}
-	buf.Flush()
+	return buf.Flush()
 }
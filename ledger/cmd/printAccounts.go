@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"slices"
 	"strings"
 	"time"
 
@@ -12,13 +13,14 @@ import (
 
 var accountLeavesOnly bool
 var accountMatchDepth bool
+var accountUnusedOnly bool
 
 // accountsCmd represents the accounts command
 var accountsCmd = &cobra.Command{
 	Use:   "accounts [account-substring-filter]...",
 	Short: "Print accounts list",
 	Run: func(cmd *cobra.Command, args []string) {
-		generalLedger, err := cliTransactions(cmd)
+		journal, err := cliJournal(cmd)
 		if err != nil {
 			log.Fatalln(err)
 		}
@@ -32,25 +34,56 @@ var accountsCmd = &cobra.Command{
 			filterDepth = strings.Count(args[0], ":")
 		}
 
-		balances := ledger.GetBalances(generalLedger, args)
+		balances, err := ledger.GetBalances(journal.Transactions, args)
+		if err != nil {
+			log.Fatalln(err)
+		}
 
-		children := make(map[string]int)
+		used := make(map[string]bool)
+		var accountNames []string
 		for _, acc := range balances {
-			if i := strings.LastIndex(acc.Name, ":"); i >= 0 {
-				children[acc.Name[:i]]++
+			if !used[acc.Name] {
+				used[acc.Name] = true
+				accountNames = append(accountNames, acc.Name)
 			}
 		}
 
-		for _, acc := range balances {
+		// Declared accounts are listed even when there are no postings
+		unused := make(map[string]bool)
+		for _, decl := range journal.Accounts {
+			inFilter := len(args) == 0
+			for _, filter := range args {
+				if strings.Contains(decl.Name, filter) {
+					inFilter = true
+				}
+			}
+			if inFilter && !used[decl.Name] && !unused[decl.Name] {
+				unused[decl.Name] = true
+				accountNames = append(accountNames, decl.Name)
+			}
+		}
+		slices.Sort(accountNames)
+
+		children := make(map[string]int)
+		for _, accName := range accountNames {
+			if i := strings.LastIndex(accName, ":"); i >= 0 {
+				children[accName[:i]]++
+			}
+		}
+
+		for _, accName := range accountNames {
 			match := true
-			if accountLeavesOnly && children[acc.Name] > 0 {
+			if accountLeavesOnly && children[accName] > 0 {
+				match = false
+			}
+			if accountMatchDepth && filterDepth != strings.Count(accName, ":") {
 				match = false
 			}
-			if accountMatchDepth && filterDepth != strings.Count(acc.Name, ":") {
+			if accountUnusedOnly && !unused[accName] {
 				match = false
 			}
 			if match {
-				fmt.Println(acc.Name)
+				fmt.Println(accName)
 			}
 		}
 	},
@@ -66,4 +99,5 @@ func init() {
 	accountsCmd.Flags().StringVarP(&endString, "end-date", "e", endDate.Format(transactionDateFormat), "End date of transaction processing.")
 	accountsCmd.Flags().BoolVarP(&accountLeavesOnly, "leaves-only", "l", false, "Only show most-depth accounts")
 	accountsCmd.Flags().BoolVarP(&accountMatchDepth, "match-depth", "m", false, "Show accounts with same depth as filter")
+	accountsCmd.Flags().BoolVarP(&accountUnusedOnly, "unused", "u", false, "Only show declared accounts without postings")
 }
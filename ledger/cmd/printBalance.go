@@ -16,20 +16,42 @@ var balanceCmd = &cobra.Command{
 	Use:     "balance [account-substring-filter]...",
 	Short:   "Print account balances",
 	Run: func(cmd *cobra.Command, args []string) {
-		generalLedger, err := cliTransactions(cmd)
+		journal, err := cliJournal(cmd)
 		if err != nil {
 			log.Fatalln(err)
 		}
+		generalLedger := journal.Transactions
+		valuation := marketValue || exchangeCommodity != ""
 		if period == "" {
-			PrintBalances(ledger.GetBalances(generalLedger, args), showEmptyAccounts, transactionDepth, columnWidth)
+			balances, err := ledger.GetBalances(generalLedger, args)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if valuation {
+				if balances, err = journal.Prices.MarketBalances(balances, exchangeCommodity, reportDate(cmd)); err != nil {
+					log.Fatalln(err)
+				}
+			}
+			if err := PrintBalances(balances, showEmptyAccounts, transactionDepth, columnWidth); err != nil {
+				log.Fatalln(err)
+			}
 		} else {
 			lperiod := strToPeriod(period)
 			rtrans := ledger.TransactionsByPeriod(generalLedger, lperiod)
 			for rIdx, rt := range rtrans {
-				balances := ledger.GetBalances(rt.Transactions, args)
+				balances, err := ledger.GetBalances(rt.Transactions, args)
+				if err != nil {
+					log.Fatalln(err)
+				}
 				if len(balances) < 1 {
 					continue
 				}
+				if valuation {
+					// value at the end of the last day of the period
+					if balances, err = journal.Prices.MarketBalances(balances, exchangeCommodity, rt.End.AddDate(0, 0, 1).Add(-time.Nanosecond)); err != nil {
+						log.Fatalln(err)
+					}
+				}
 
 				if rIdx > 0 {
 					fmt.Println("")
@@ -37,7 +59,9 @@ var balanceCmd = &cobra.Command{
 				}
 				fmt.Println(rt.Start.Format(transactionDateFormat), "-", rt.End.Format(transactionDateFormat))
 				fmt.Println(strings.Repeat("=", columnWidth))
-				PrintBalances(balances, showEmptyAccounts, transactionDepth, columnWidth)
+				if err := PrintBalances(balances, showEmptyAccounts, transactionDepth, columnWidth); err != nil {
+					log.Fatalln(err)
+				}
 			}
 		}
 	},
@@ -58,4 +82,13 @@ func init() {
 	balanceCmd.Flags().StringVar(&period, "period", "", "Split output into periods (Monthly,Quarterly,SemiYearly,Yearly).")
 	balanceCmd.Flags().BoolVar(&showEmptyAccounts, "empty", false, "Show empty (zero balance) accounts.")
 	balanceCmd.Flags().IntVar(&transactionDepth, "depth", -1, "Depth of transaction output (balance).")
+	balanceCmd.Flags().BoolVarP(&marketValue, "market", "V", false, "Show balances at market value, using P directive prices.")
+	balanceCmd.Flags().StringVarP(&exchangeCommodity, "exchange", "X", "", "Show balances at market value in this commodity.")
+	balanceCmd.Flags().BoolVar(&statusCleared, "cleared", false, "Only include cleared (*) postings.")
+	balanceCmd.Flags().BoolVar(&statusPending, "pending", false, "Only include pending (!) postings.")
+	balanceCmd.Flags().BoolVar(&statusUncleared, "uncleared", false, "Only include uncleared postings.")
+	balanceCmd.Flags().BoolVar(&effectiveDates, "effective", false, "Use the effective dates of transactions and postings.")
+	balanceCmd.Flags().BoolVar(&realOnly, "real", false, "Only include real postings, excluding virtual postings.")
+	balanceCmd.Flags().StringArrayVar(&tagFilters, "tag", nil, "Only include postings with this tag (name or name=value).")
+	balanceCmd.Flags().StringVar(&forecastString, "forecast", "", "Add transactions from periodic transactions, from today until this date.")
 }
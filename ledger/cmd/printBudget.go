@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/howeyc/ledger"
+	"github.com/howeyc/ledger/ledger/cmd/internal/fastcolor"
+	"github.com/spf13/cobra"
+)
+
+// budgetCmd represents the budget command
+var budgetCmd = &cobra.Command{
+	Use:   "budget [account-substring-filter]...",
+	Short: "Print budget compared to actual amounts",
+	Run: func(cmd *cobra.Command, args []string) {
+		journal, err := cliJournal(cmd)
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		lperiod := strToPeriod(period)
+		rbudgets, err := ledger.BudgetByPeriod(journal.Transactions, journal.Periodic, lperiod)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		for rIdx, rb := range rbudgets {
+			var budgets []*ledger.BudgetAccount
+			for _, budget := range rb.Accounts {
+				inFilter := len(args) == 0
+				for _, filter := range args {
+					if strings.Contains(budget.Name, filter) {
+						inFilter = true
+					}
+				}
+				if inFilter {
+					budgets = append(budgets, budget)
+				}
+			}
+
+			if len(rbudgets) > 1 {
+				if rIdx > 0 {
+					fmt.Println("")
+					fmt.Println(strings.Repeat("=", columnWidth))
+				}
+				fmt.Println(rb.Start.Format(transactionDateFormat), "-", rb.End.Format(transactionDateFormat))
+				fmt.Println(strings.Repeat("=", columnWidth))
+			}
+			if err := PrintBudget(budgets, columnWidth); err != nil {
+				log.Fatalln(err)
+			}
+		}
+	},
+}
+
+// PrintBudget prints the budgeted, actual, and remaining amounts, along with
+// the percent of the budget used, of each account.
+func PrintBudget(budgets []*ledger.BudgetAccount, columns int) error {
+	var commodities []ledger.Account
+	for _, budget := range budgets {
+		commodities = append(commodities, ledger.Account{Commodity: budget.Commodity})
+	}
+
+	// Calculate widths: three amount columns and percent, rest for accountname
+	amtWidth := amountWidth(commodities)
+	pctWidth := 6
+	if columns < 3*(amtWidth+1)+pctWidth+2 {
+		columns = 3*(amtWidth+1) + pctWidth + 2
+		fmt.Fprintf(os.Stderr, "warning: `columns` too small, setting to %d\n", columns)
+	}
+	accWidth := columns - 3*(amtWidth+1) - pctWidth - 1
+
+	colorNeg := fastcolor.FgRed
+	colorAccount := fastcolor.FgBlue
+	colorReset := fastcolor.Reset
+
+	var amtBuf [64]byte
+
+	buf := bufio.NewWriter(os.Stdout)
+	colorReset.WriteStringFixed(buf, "", accWidth, false)
+	for _, heading := range []string{"Budget", "Actual", "Remaining"} {
+		buf.WriteString(" ")
+		colorReset.WriteStringFixed(buf, heading, amtWidth, true)
+	}
+	buf.WriteString(" ")
+	colorReset.WriteStringFixed(buf, "Used", pctWidth, true)
+	buf.WriteString(newLine)
+	fmt.Fprintln(buf, strings.Repeat("-", columns))
+
+	for _, budget := range budgets {
+		colorAccount.WriteStringFixed(buf, budget.Name, accWidth, false)
+		buf.WriteString(" ")
+		colorReset.WriteStringFixed(buf, formatAmount(amtBuf[:], budget.Budget, budget.Commodity), amtWidth, true)
+		buf.WriteString(" ")
+		colorReset.WriteStringFixed(buf, formatAmount(amtBuf[:], budget.Actual, budget.Commodity), amtWidth, true)
+		buf.WriteString(" ")
+		remaining, err := budget.Remaining()
+		if err != nil {
+			return fmt.Errorf("remaining of %s: %w", budget.Name, err)
+		}
+		percent, err := budget.PercentUsed()
+		if err != nil {
+			return fmt.Errorf("percent used of %s: %w", budget.Name, err)
+		}
+		amtColor := colorReset
+		if remaining.Sign() != 0 && remaining.Sign() != budget.Budget.Sign() {
+			amtColor = colorNeg
+		}
+		amtColor.WriteStringFixed(buf, formatAmount(amtBuf[:], remaining, budget.Commodity), amtWidth, true)
+		buf.WriteString(" ")
+		colorReset.WriteStringFixed(buf, percent.StringRound()+"%", pctWidth, true)
+		buf.WriteString(newLine)
+	}
+	return buf.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(budgetCmd)
+
+	var startDate, endDate time.Time
+	startDate = time.Date(1970, 1, 1, 0, 0, 0, 0, time.Local)
+	endDate = time.Now().Add(1<<63 - 1)
+	budgetCmd.Flags().StringVarP(&startString, "begin-date", "b", startDate.Format(transactionDateFormat), "Begin date of transaction processing.")
+	budgetCmd.Flags().StringVarP(&endString, "end-date", "e", endDate.Format(transactionDateFormat), "End date of transaction processing.")
+	budgetCmd.Flags().StringVar(&payeeFilter, "payee", "", "Filter output to payees that contain this string.")
+	budgetCmd.Flags().IntVar(&columnWidth, "columns", 80, "Set a column width for output.")
+	budgetCmd.Flags().BoolVar(&columnWide, "wide", false, "Wide output (use terminal width).")
+
+	budgetCmd.Flags().StringVar(&period, "period", "", "Split output into periods (Monthly,Quarterly,SemiYearly,Yearly).")
+}
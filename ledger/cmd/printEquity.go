@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"cmp"
 	"log"
 	"os"
 	"slices"
@@ -30,7 +31,7 @@ var equityCmd = &cobra.Command{
 		}
 
 		filterArr := args
-		balances := make(map[string]decimal.Decimal)
+		balances := make(map[balanceKey]decimal.Decimal)
 		for _, trans := range generalLedger {
 			for _, accChange := range trans.AccountChanges {
 				inFilter := len(filterArr) == 0
@@ -40,32 +41,47 @@ var equityCmd = &cobra.Command{
 					}
 				}
 				if inFilter {
-					if decNum, ok := balances[accChange.Name]; !ok {
-						balances[accChange.Name] = accChange.Balance
-					} else {
-						balances[accChange.Name] = decNum.Add(accChange.Balance)
+					key := balanceKey{name: accChange.Name, commodity: accChange.Commodity}
+					if decNum, ok := balances[key]; !ok {
+						balances[key] = accChange.Balance
+					} else if balances[key], err = decNum.CheckedAdd(accChange.Balance); err != nil {
+						log.Fatalf("balance of %s: %s", accChange.Name, err)
 					}
 				}
 			}
 		}
 
-		eqBal := decimal.Zero
-		for name, bal := range balances {
+		eqBals := make(map[string]decimal.Decimal)
+		for key, bal := range balances {
 			if !bal.IsZero() {
 				trans.AccountChanges = append(trans.AccountChanges, ledger.Account{
-					Name:    name,
-					Balance: bal,
+					Name:      key.name,
+					Balance:   bal,
+					Commodity: key.commodity,
 				})
 			}
-			eqBal = eqBal.Add(bal)
+			if eqBals[key.commodity], err = eqBals[key.commodity].CheckedAdd(bal); err != nil {
+				log.Fatalf("balance of Equity: %s", err)
+			}
+		}
+		for commodity, eqBal := range eqBals {
+			trans.AccountChanges = append(trans.AccountChanges, ledger.Account{
+				Name:      "Equity",
+				Balance:   eqBal.Neg(),
+				Commodity: commodity,
+			})
+		}
+		if len(eqBals) == 0 {
+			trans.AccountChanges = append(trans.AccountChanges, ledger.Account{
+				Name: "Equity",
+			})
 		}
-		trans.AccountChanges = append(trans.AccountChanges, ledger.Account{
-			Name:    "Equity",
-			Balance: eqBal.Neg(),
-		})
 
 		slices.SortFunc(trans.AccountChanges, func(a, b ledger.Account) int {
-			return strings.Compare(a.Name, b.Name)
+			return cmp.Or(
+				strings.Compare(a.Name, b.Name),
+				strings.Compare(a.Commodity, b.Commodity),
+			)
 		})
 
 		WriteTransaction(os.Stdout, &trans, 80)
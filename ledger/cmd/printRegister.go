@@ -16,12 +16,18 @@ var registerCmd = &cobra.Command{
 	Use:     "register [account-substring-filter]...",
 	Short:   "Print register of transactions",
 	Run: func(cmd *cobra.Command, args []string) {
-		generalLedger, err := cliTransactions(cmd)
+		journal, err := cliJournal(cmd)
 		if err != nil {
 			log.Fatalln(err)
 		}
+		generalLedger := journal.Transactions
+		if marketValue || exchangeCommodity != "" {
+			generalLedger = marketTransactions(generalLedger, journal.Prices, exchangeCommodity, reportDate(cmd))
+		}
 		if period == "" {
-			PrintRegister(generalLedger, args, columnWidth)
+			if err := PrintRegister(generalLedger, args, columnWidth); err != nil {
+				log.Fatalln(err)
+			}
 		} else {
 			lperiod := strToPeriod(period)
 			rtrans := ledger.TransactionsByPeriod(generalLedger, lperiod)
@@ -35,7 +41,9 @@ var registerCmd = &cobra.Command{
 				}
 				fmt.Println(rt.Start.Format(transactionDateFormat), "-", rt.End.Format(transactionDateFormat))
 				fmt.Println(strings.Repeat("=", columnWidth))
-				PrintRegister(rt.Transactions, args, columnWidth)
+				if err := PrintRegister(rt.Transactions, args, columnWidth); err != nil {
+					log.Fatalln(err)
+				}
 			}
 		}
 	},
@@ -54,4 +62,13 @@ func init() {
 	registerCmd.Flags().BoolVar(&columnWide, "wide", false, "Wide output (use terminal width).")
 
 	registerCmd.Flags().StringVar(&period, "period", "", "Split output into periods (Monthly,Quarterly,SemiYearly,Yearly).")
+	registerCmd.Flags().BoolVarP(&marketValue, "market", "V", false, "Show amounts at market value, using P directive prices.")
+	registerCmd.Flags().StringVarP(&exchangeCommodity, "exchange", "X", "", "Show amounts at market value in this commodity.")
+	registerCmd.Flags().BoolVar(&statusCleared, "cleared", false, "Only include cleared (*) postings.")
+	registerCmd.Flags().BoolVar(&statusPending, "pending", false, "Only include pending (!) postings.")
+	registerCmd.Flags().BoolVar(&statusUncleared, "uncleared", false, "Only include uncleared postings.")
+	registerCmd.Flags().BoolVar(&effectiveDates, "effective", false, "Use the effective dates of transactions and postings.")
+	registerCmd.Flags().BoolVar(&realOnly, "real", false, "Only include real postings, excluding virtual postings.")
+	registerCmd.Flags().StringArrayVar(&tagFilters, "tag", nil, "Only include postings with this tag (name or name=value).")
+	registerCmd.Flags().StringVar(&forecastString, "forecast", "", "Add transactions from periodic transactions, from today until this date.")
 }
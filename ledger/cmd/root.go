@@ -5,18 +5,26 @@ import (
 	"os"
 	"runtime/pprof"
 
+	"github.com/howeyc/ledger/decimal"
 	cc "github.com/ivanpirog/coloredcobra"
 	"github.com/spf13/cobra"
 )
 
 var cpuprofile string
+var numberFormatExample string
 var cpuf *os.File
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "ledger",
 	Short: "Plain text accounting",
-	PersistentPreRun: func(_ *cobra.Command, _ []string) {
+	PersistentPreRun: func(cmd *cobra.Command, _ []string) {
+		if cmd.Flags().Changed("number-format") {
+			var err error
+			if numberFormat, err = decimal.ParseNumberFormat(numberFormatExample); err != nil {
+				log.Fatal("could not set number format: ", err)
+			}
+		}
 		if cpuprofile != "" {
 			var err error
 			cpuf, err = os.Create(cpuprofile)
@@ -63,6 +71,7 @@ func init() {
 	ledgerFilePath = os.Getenv("LEDGER_FILE")
 
 	rootCmd.PersistentFlags().StringVarP(&ledgerFilePath, "file", "f", ledgerFilePath, "ledger file (default is $LEDGER_FILE)")
+	rootCmd.PersistentFlags().StringVar(&numberFormatExample, "number-format", "1234.56", "format of amounts, by `example` such as 1,234.56 or 1.234,56 or (1,234.56)")
 	rootCmd.PersistentFlags().StringVarP(&cpuprofile, "prof", "", "", "write cpu profile to `file`")
 }
 
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"slices"
+	"sync"
 	"time"
 
 	"github.com/howeyc/ledger/ledger/cmd/internal/httpcompress"
@@ -28,15 +29,41 @@ var contentStatic embed.FS
 //go:embed templates/*
 var contentTemplates embed.FS
 
+// journalCache parses the ledger file again only once it, or a file it
+// includes, has changed
+var journalCache *ledger.JournalCache
+
+// sortedJournal is the last journal from the cache, sorted by date
+var sortedJournal *ledger.Journal
+var sortedJournalMu sync.Mutex
+
+// getJournal returns the journal of the ledger file, sorted by date. The
+// journal is shared by every request until the ledger file changes, so must
+// not be changed.
+func getJournal() (*ledger.Journal, error) {
+	sortedJournalMu.Lock()
+	defer sortedJournalMu.Unlock()
+
+	journal, jerr := journalCache.Journal()
+	if jerr != nil {
+		return nil, fmt.Errorf("%s", jerr.Error())
+	}
+	if journal != sortedJournal {
+		slices.SortStableFunc(journal.Transactions, func(a, b *ledger.Transaction) int {
+			return a.Date.Compare(b.Date)
+		})
+		sortedJournal = journal
+		setCommodityPlaces(journal)
+	}
+	return journal, nil
+}
+
 func getTransactions() ([]*ledger.Transaction, error) {
-	trans, terr := ledger.ParseLedgerFile(ledgerFilePath)
-	if terr != nil {
-		return nil, fmt.Errorf("%s", terr.Error())
+	journal, err := getJournal()
+	if err != nil {
+		return nil, err
 	}
-	slices.SortStableFunc(trans, func(a, b *ledger.Transaction) int {
-		return a.Date.Compare(b.Date)
-	})
-	return trans, nil
+	return journal.Transactions, nil
 }
 
 // webCmd represents the web command
@@ -47,6 +74,7 @@ var webCmd = &cobra.Command{
 		configLoaders(time.Minute * 5)
 
 		// initialize cache
+		journalCache = ledger.NewJournalCache(ledgerFilePath, parseOptions()...)
 		if _, err := getTransactions(); err != nil {
 			log.Fatalln(err)
 		}
@@ -33,6 +33,8 @@ type reportConfig struct {
 	ExcludeAccountTrans    []string            `toml:"exclude_account_trans"`
 	ExcludeAccountsSummary []string            `toml:"exclude_account_summary"`
 	CalculatedAccounts     []calculatedAccount `toml:"calculated_account"`
+	Market                 bool                `toml:"market"`
+	Exchange               string              `toml:"exchange"`
 }
 
 type reportConfigStruct struct {
@@ -76,6 +78,7 @@ type stockInfo struct {
 	PriceChangePctOverall float64
 
 	Cost            float64
+	CostCommodity   string
 	MarketValue     float64
 	GainLossDay     float64
 	GainLossOverall float64
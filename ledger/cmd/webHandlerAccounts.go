@@ -38,7 +38,11 @@ func quickviewHandler(w http.ResponseWriter, r *http.Request) {
 		includeNames[qvc.Name] = true
 	}
 
-	balances := ledger.GetBalances(trans, []string{})
+	balances, berr := ledger.GetBalances(trans, []string{})
+	if berr != nil {
+		http.Error(w, berr.Error(), 500)
+		return
+	}
 	for _, bal := range balances {
 		if includeNames[bal.Name] {
 			pData.Accounts = append(pData.Accounts, bal)
@@ -75,7 +79,7 @@ func addTransactionPostHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintln(&tbuf, "")
 
 	/* Check valid transaction is created */
-	trans, perr := ledger.ParseLedger(&tbuf)
+	trans, perr := ledger.ParseLedger(&tbuf, parseOptions()...)
 	if perr != nil {
 		http.Error(w, perr.Error(), 500)
 		return
@@ -136,7 +140,11 @@ func addQuickTransactionHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Child non-zero balance accounts
-	balances := ledger.GetBalances(atrans, []string{})
+	balances, berr := ledger.GetBalances(atrans, []string{})
+	if berr != nil {
+		http.Error(w, berr.Error(), 500)
+		return
+	}
 	var abals []*ledger.Account
 	for _, bal := range balances {
 		accDepth := len(strings.Split(bal.Name, ":"))
@@ -169,7 +177,11 @@ func addTransactionHandler(w http.ResponseWriter, _ *http.Request) {
 		http.Error(w, terr.Error(), 500)
 		return
 	}
-	balances := ledger.GetBalances(trans, []string{})
+	balances, berr := ledger.GetBalances(trans, []string{})
+	if berr != nil {
+		http.Error(w, berr.Error(), 500)
+		return
+	}
 
 	var pData pageData
 	pData.Init()
@@ -195,7 +207,11 @@ func accountsHandler(w http.ResponseWriter, _ *http.Request) {
 		return
 	}
 
-	balances := ledger.GetBalances(trans, []string{})
+	balances, berr := ledger.GetBalances(trans, []string{})
+	if berr != nil {
+		http.Error(w, berr.Error(), 500)
+		return
+	}
 
 	var pData pageData
 	pData.Init()
@@ -2,9 +2,11 @@ package cmd
 
 import (
 	"cmp"
+	"fmt"
 	"net/http"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/howeyc/ledger"
 )
@@ -25,12 +27,17 @@ func portfolioHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	trans, terr := getTransactions()
-	if terr != nil {
-		http.Error(w, terr.Error(), 500)
+	journal, jerr := getJournal()
+	if jerr != nil {
+		http.Error(w, jerr.Error(), 500)
+		return
+	}
+	trans := journal.Transactions
+	balances, berr := ledger.GetBalances(trans, []string{})
+	if berr != nil {
+		http.Error(w, berr.Error(), 500)
 		return
 	}
-	balances := ledger.GetBalances(trans, []string{})
 
 	type portPageData struct {
 		pageData
@@ -46,18 +53,48 @@ func portfolioHandler(w http.ResponseWriter, r *http.Request) {
 	pData.ShowDividends = portfolio.ShowDividends
 	pData.ShowWeight = portfolio.ShowWeight
 
-	sectionTotals := make(map[string]stockInfo)
+	// The cost of a stock is kept in one commodity, a cost in more than one
+	// is valued in the first
+	costs := make([]float64, len(portfolio.Stocks))
+	costCommodities := make([]string, len(portfolio.Stocks))
+	for i, stock := range portfolio.Stocks {
+		costBasis, cerr := ledger.GetCostBasis(trans, stock.Account)
+		if cerr != nil {
+			http.Error(w, cerr.Error(), 500)
+			return
+		}
+		if len(costBasis) > 0 {
+			costCommodities[i] = costBasis[0].Commodity
+		}
+		for _, cost := range costBasis {
+			value, _, ok := journal.Prices.Value(cost.Balance, cost.Commodity, costCommodities[i], time.Now())
+			if !ok {
+				http.Error(w, fmt.Sprintf("unable to value cost of %s in %s as %s", stock.Account, cost.Commodity, costCommodities[i]), 500)
+				return
+			}
+			c, _ := value.Float64()
+			costs[i] += c
+		}
+	}
+
 	siChan := make(chan stockInfo)
 
-	for _, stock := range portfolio.Stocks {
+	for i, stock := range portfolio.Stocks {
 		go func(name, account, symbol, securityType, section string, shares float64) {
 			si := stockInfo{Name: name,
 				Section: section,
 				Ticker:  symbol,
-				Shares:  shares}
-			for _, bal := range balances {
-				if account == bal.Name {
-					si.Cost, _ = bal.Balance.Float64()
+				Shares:  shares,
+
+				Cost:          costs[i],
+				CostCommodity: costCommodities[i]}
+			// Shares held as a commodity in the ledger are used when no
+			// shares are configured
+			if si.Shares == 0 {
+				for _, bal := range balances {
+					if account == bal.Name && len(bal.Commodity) > 0 {
+						si.Shares, _ = bal.Balance.Float64()
+					}
 				}
 			}
 
@@ -72,7 +109,7 @@ func portfolioHandler(w http.ResponseWriter, r *http.Request) {
 				}
 				if portfolio.ShowDividends {
 					div := fundAnnualDividends(symbol)
-					si.AnnualDividends = div * shares
+					si.AnnualDividends = div * si.Shares
 				}
 			case "Crypto":
 				quote, qerr := cryptoQuote(symbol)
@@ -80,6 +117,8 @@ func portfolioHandler(w http.ResponseWriter, r *http.Request) {
 					sprice = quote.Last
 					sclose = quote.PreviousClose
 				}
+			case "Journal":
+				sprice, sclose, _ = journalQuote(journal.Prices, symbol)
 			case "Cash":
 				sprice = 1
 				sclose = 1
@@ -89,6 +128,10 @@ func portfolioHandler(w http.ResponseWriter, r *http.Request) {
 				sclose = cprice
 			}
 
+			// Prices in the journal are used when no quote is available
+			if sprice == 0 && sclose == 0 {
+				sprice, sclose, _ = journalQuote(journal.Prices, symbol)
+			}
 			if sprice == 0 {
 				sprice = sclose
 			}
@@ -109,42 +152,64 @@ func portfolioHandler(w http.ResponseWriter, r *http.Request) {
 		pData.Stocks = append(pData.Stocks, <-siChan)
 	}
 
-	stotal := stockInfo{Name: "Total", Section: "zzzTotal", Type: "Total"}
+	// Totals are kept for each cost commodity, named with the commodity when
+	// there is more than one
+	type sectionKey struct {
+		section, commodity string
+	}
+	sectionTotals := make(map[sectionKey]stockInfo)
+	totals := make(map[string]stockInfo)
 	for _, si := range pData.Stocks {
-		sectionInfo := sectionTotals[si.Section]
+		sectionInfo := sectionTotals[sectionKey{si.Section, si.CostCommodity}]
 		sectionInfo.Name = si.Section
 		sectionInfo.Section = si.Section
 		sectionInfo.Type = "Section Total"
-		sectionInfo.Ticker = "zzz"
+		sectionInfo.Ticker = "zzz" + si.CostCommodity
+		sectionInfo.CostCommodity = si.CostCommodity
 		sectionInfo.Cost += si.Cost
 		sectionInfo.MarketValue += si.MarketValue
 		sectionInfo.GainLossOverall += si.GainLossOverall
 		sectionInfo.GainLossDay += si.GainLossDay
 		sectionInfo.AnnualDividends += si.AnnualDividends
 		sectionInfo.AnnualYield = (sectionInfo.AnnualDividends / sectionInfo.MarketValue) * 100
-		sectionTotals[si.Section] = sectionInfo
-
+		sectionTotals[sectionKey{si.Section, si.CostCommodity}] = sectionInfo
+
+		stotal := totals[si.CostCommodity]
+		stotal.Name = "Total"
+		stotal.Section = "zzzTotal"
+		stotal.Type = "Total"
+		stotal.Ticker = si.CostCommodity
+		stotal.CostCommodity = si.CostCommodity
 		stotal.Cost += si.Cost
 		stotal.MarketValue += si.MarketValue
 		stotal.GainLossOverall += si.GainLossOverall
 		stotal.GainLossDay += si.GainLossDay
 		stotal.AnnualDividends += si.AnnualDividends
+		totals[si.CostCommodity] = stotal
+	}
+	for commodity, stotal := range totals {
+		if len(totals) > 1 {
+			stotal.Name += " " + commodity
+		}
+		stotal.PriceChangePctDay = (stotal.GainLossDay / stotal.Cost) * 100.0
+		stotal.PriceChangePctOverall = (stotal.GainLossOverall / stotal.Cost) * 100.0
+		stotal.AnnualYield = (stotal.AnnualDividends / stotal.MarketValue) * 100
+		pData.Stocks = append(pData.Stocks, stotal)
 	}
-	stotal.PriceChangePctDay = (stotal.GainLossDay / stotal.Cost) * 100.0
-	stotal.PriceChangePctOverall = (stotal.GainLossOverall / stotal.Cost) * 100.0
-	stotal.AnnualYield = (stotal.AnnualDividends / stotal.MarketValue) * 100
-	pData.Stocks = append(pData.Stocks, stotal)
 
-	for _, sectionInfo := range sectionTotals {
+	for key, sectionInfo := range sectionTotals {
 		sectionInfo.PriceChangePctDay = (sectionInfo.GainLossDay / sectionInfo.Cost) * 100.0
 		sectionInfo.PriceChangePctOverall = (sectionInfo.GainLossOverall / sectionInfo.Cost) * 100.0
 
 		for i, si := range pData.Stocks {
-			if si.Section == sectionInfo.Name {
+			if si.Type == "" && si.Section == key.section && si.CostCommodity == key.commodity {
 				pData.Stocks[i].Weight = (si.MarketValue / sectionInfo.MarketValue) * 100
 			}
 		}
-		sectionInfo.Weight = (sectionInfo.MarketValue / stotal.MarketValue) * 100
+		sectionInfo.Weight = (sectionInfo.MarketValue / totals[key.commodity].MarketValue) * 100
+		if len(totals) > 1 {
+			sectionInfo.Name += " " + key.commodity
+		}
 
 		pData.Stocks = append(pData.Stocks, sectionInfo)
 	}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"cmp"
 	"fmt"
 	"net/http"
 	"slices"
@@ -67,7 +68,9 @@ func getAccounts(accountNeedle string, accountsHaystack []*ledger.Account) (resu
 	return
 }
 
-func calcBalances(calcAccts []calculatedAccount, balances []*ledger.Account) (results []*ledger.Account) {
+// calcBalances returns the balances of the calculated accounts. Products
+// and quotients are banker rounded.
+func calcBalances(calcAccts []calculatedAccount, balances []*ledger.Account) (results []*ledger.Account, err error) {
 	accVals := make(map[string]decimal.Decimal)
 	for _, calcAccount := range calcAccts {
 		for _, bal := range balances {
@@ -80,7 +83,9 @@ func calcBalances(calcAccts []calculatedAccount, balances []*ledger.Account) (re
 					}
 					if acctOp.MultiplicationFactor != 0 {
 						factor := decimal.NewFromFloat(acctOp.MultiplicationFactor)
-						fval = fval.Mul(factor)
+						if fval, err = fval.MulRound(factor, decimal.RoundHalfEven); err != nil {
+							return nil, fmt.Errorf("calculated account %s: %w", calcAccount.Name, err)
+						}
 					}
 					oval := decimal.One
 					if acctOp.SubAccount != "" {
@@ -92,13 +97,16 @@ func calcBalances(calcAccts []calculatedAccount, balances []*ledger.Account) (re
 					}
 					switch acctOp.Operation {
 					case "+":
-						aval = aval.Add(fval)
+						aval, err = aval.CheckedAdd(fval)
 					case "-":
-						aval = aval.Sub(fval)
+						aval, err = aval.CheckedSub(fval)
 					case "*":
-						aval = fval.Mul(oval)
+						aval, err = fval.MulRound(oval, decimal.RoundHalfEven)
 					case "/":
-						aval = fval.Div(oval)
+						aval, err = fval.DivRound(oval, decimal.RoundHalfEven)
+					}
+					if err != nil {
+						return nil, fmt.Errorf("calculated account %s: %w", calcAccount.Name, err)
 					}
 					accVals[calcAccount.Name] = aval
 				}
@@ -120,39 +128,49 @@ func calcBalances(calcAccts []calculatedAccount, balances []*ledger.Account) (re
 	return
 }
 
-// Merge multiple account changes for each distinct account
-func mergeAccounts(input *ledger.Transaction) {
-	balmap := make(map[string]decimal.Decimal)
+// Merge multiple account changes for each distinct account and commodity
+func mergeAccounts(input *ledger.Transaction) error {
+	balmap := make(map[balanceKey]decimal.Decimal)
 	for _, accChange := range input.AccountChanges {
-		if bal, found := balmap[accChange.Name]; found {
-			bal = bal.Add(accChange.Balance)
-			balmap[accChange.Name] = bal
+		key := balanceKey{name: accChange.Name, commodity: accChange.Commodity}
+		if bal, found := balmap[key]; found {
+			bal, err := bal.CheckedAdd(accChange.Balance)
+			if err != nil {
+				return fmt.Errorf("balance of %s: %w", accChange.Name, err)
+			}
+			balmap[key] = bal
 		} else {
-			balmap[accChange.Name] = accChange.Balance
+			balmap[key] = accChange.Balance
 		}
 	}
 	input.AccountChanges = []ledger.Account{}
-	for accName, bal := range balmap {
+	for key, bal := range balmap {
 		input.AccountChanges = append(input.AccountChanges, ledger.Account{
-			Name:    accName,
-			Balance: bal,
+			Name:      key.name,
+			Balance:   bal,
+			Commodity: key.commodity,
 		})
 	}
 
 	// Map is random order, order by name for consistency (helps with tests)
 	slices.SortFunc(input.AccountChanges, func(a, b ledger.Account) int {
-		return strings.Compare(a.Name, b.Name)
+		return cmp.Or(
+			strings.Compare(a.Name, b.Name),
+			strings.Compare(a.Commodity, b.Commodity),
+		)
 	})
+	return nil
 }
 
 func reportHandler(w http.ResponseWriter, r *http.Request) {
 	reportName := r.PathValue("reportName")
 
-	trans, terr := getTransactions()
-	if terr != nil {
-		http.Error(w, terr.Error(), 500)
+	journal, jerr := getJournal()
+	if jerr != nil {
+		http.Error(w, jerr.Error(), 500)
 		return
 	}
+	trans := journal.Transactions
 
 	var rConf reportConfig
 	for _, reportConf := range reportConfigData.Reports {
@@ -184,12 +202,29 @@ func reportHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	balances := ledger.GetBalances(rtrans, []string{})
+	valuation := rConf.Market || rConf.Exchange != ""
+
+	balances, berr := ledger.GetBalances(rtrans, []string{})
+	if berr != nil {
+		http.Error(w, berr.Error(), 500)
+		return
+	}
+	if valuation {
+		if balances, berr = journal.Prices.MarketBalances(balances, rConf.Exchange, rEnd); berr != nil {
+			http.Error(w, berr.Error(), 500)
+			return
+		}
+	}
 	var initialAccounts []*ledger.Account
 	for _, confAccount := range rConf.Accounts {
 		initialAccounts = append(initialAccounts, getAccounts(confAccount, balances)...)
 	}
-	initialAccounts = append(initialAccounts, calcBalances(rConf.CalculatedAccounts, balances)...)
+	calcAccounts, cerr := calcBalances(rConf.CalculatedAccounts, balances)
+	if cerr != nil {
+		http.Error(w, cerr.Error(), 500)
+		return
+	}
+	initialAccounts = append(initialAccounts, calcAccounts...)
 	var reportSummaryAccounts []*ledger.Account
 	for _, account := range initialAccounts {
 		include := true
@@ -216,8 +251,13 @@ func reportHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		if include {
-			mergeAccounts(trans)
-			vtrans = append(vtrans, trans)
+			// merge a copy, the journal is shared with other requests
+			merged := *trans
+			if merr := mergeAccounts(&merged); merr != nil {
+				http.Error(w, merr.Error(), 500)
+				return
+			}
+			vtrans = append(vtrans, &merged)
 		}
 	}
 
@@ -294,6 +334,87 @@ func reportHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), 500)
 		}
 
+	case "budget":
+		type budgetAccount struct {
+			Name                      string
+			Budget, Actual, Remaining ledger.Account
+			Percentage, Width         int
+		}
+
+		budgets, berr := ledger.GetBudget(rtrans, journal.Periodic, rStart, rEnd)
+		if berr != nil {
+			http.Error(w, berr.Error(), 500)
+			return
+		}
+
+		// Only the budgets of accounts in the report
+		budgetNames := make([]*ledger.Account, 0, len(budgets))
+		for _, budget := range budgets {
+			budgetNames = append(budgetNames, &ledger.Account{Name: budget.Name})
+		}
+		reportNames := make(map[string]bool)
+		for _, confAccount := range rConf.Accounts {
+			for _, acc := range getAccounts(confAccount, budgetNames) {
+				reportNames[acc.Name] = true
+			}
+		}
+
+		var values []budgetAccount
+		for _, budget := range budgets {
+			if len(rConf.Accounts) > 0 && !reportNames[budget.Name] {
+				continue
+			}
+			remaining, rerr := budget.Remaining()
+			percent, perr := budget.PercentUsed()
+			if berr = cmp.Or(rerr, perr); berr != nil {
+				http.Error(w, berr.Error(), 500)
+				return
+			}
+			pf, _ := percent.Float64()
+			values = append(values, budgetAccount{
+				Name:       budget.Name,
+				Budget:     ledger.Account{Balance: budget.Budget, Commodity: budget.Commodity},
+				Actual:     ledger.Account{Balance: budget.Actual, Commodity: budget.Commodity},
+				Remaining:  ledger.Account{Balance: remaining, Commodity: budget.Commodity},
+				Percentage: int(pf),
+				Width:      min(max(int(pf), 0), 100),
+			})
+		}
+
+		type budgetPageData struct {
+			pageData
+			ReportName           string
+			RangeStart, RangeEnd time.Time
+			ChartType            string
+			ChartAccounts        []budgetAccount
+		}
+
+		var pData budgetPageData
+		pData.Init()
+		pData.Transactions = vtrans
+		pData.ChartType = "Budget"
+		pData.ChartAccounts = values
+		pData.RangeStart = rStart
+		pData.RangeEnd = rEnd
+		pData.ReportName = reportName
+
+		pData.AccountNames = []string{"All"}
+		for _, ca := range pData.ChartAccounts {
+			pData.AccountNames = append(pData.AccountNames, ca.Name)
+		}
+		sort.Strings(pData.AccountNames[1:])
+		pData.AccountNames = slices.Compact(pData.AccountNames)
+
+		t, err := loadTemplates("templates/template.budgetchart.html")
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+		err = t.Execute(w, pData)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+		}
+
 	case "pie", "polar", "doughnut":
 		type pieAccount struct {
 			Name      string
@@ -396,7 +517,17 @@ func reportHandler(w http.ResponseWriter, r *http.Request) {
 			rType = rConf.RangeBalanceType
 		}
 
-		rangeBalances := ledger.BalancesByPeriod(rtrans, rPeriod, rType)
+		var rangeBalances []*ledger.RangeBalance
+		var berr error
+		if valuation {
+			rangeBalances, berr = ledger.MarketBalancesByPeriod(rtrans, rPeriod, rType, journal.Prices, rConf.Exchange)
+		} else {
+			rangeBalances, berr = ledger.BalancesByPeriod(rtrans, rPeriod, rType)
+		}
+		if berr != nil {
+			http.Error(w, berr.Error(), 500)
+			return
+		}
 		for _, rb := range rangeBalances {
 			if rConf.RangeBalanceSkipZero {
 				allZero := true
@@ -424,7 +555,12 @@ func reportHandler(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 
-			for _, calcAccount := range calcBalances(rConf.CalculatedAccounts, rb.Balances) {
+			calcAccounts, cerr := calcBalances(rConf.CalculatedAccounts, rb.Balances)
+			if cerr != nil {
+				http.Error(w, cerr.Error(), 500)
+				return
+			}
+			for _, calcAccount := range calcAccounts {
 				accVals[calcAccount.Name] = calcAccount.Balance
 			}
 
@@ -6,6 +6,8 @@ import (
 	"path"
 	"strings"
 
+	"github.com/howeyc/ledger"
+
 	"github.com/juztin/numeronym"
 )
 
@@ -33,6 +35,11 @@ func qvshortname(accname string) string {
 	return abbrev(accname)
 }
 
+func amount(acc ledger.Account) string {
+	var amtBuf [64]byte
+	return strings.Clone(formatAmount(amtBuf[:], acc.Balance, acc.Commodity))
+}
+
 func loadTemplates(filenames ...string) (*template.Template, error) {
 	if len(filenames) == 0 {
 		// Not really a problem, but be consistent.
@@ -40,6 +47,7 @@ func loadTemplates(filenames ...string) (*template.Template, error) {
 	}
 	funcMap := template.FuncMap{
 		"abbrev":      abbrev,
+		"amount":      amount,
 		"lastaccount": lastaccount,
 		"qvshortname": qvshortname,
 		"substr":      strings.Contains,
@@ -13,6 +13,10 @@ type linescanner struct {
 
 	filename  string
 	lineCount int
+
+	line   string
+	unread bool
+	repeat bool
 }
 
 // NewLineScanner creates a wrapper around bufio.Scanner with pre-allocated
@@ -37,10 +41,25 @@ func newLineScanner(filename string, r io.Reader) *linescanner {
 }
 
 func (lp *linescanner) Scan() bool {
+	if lp.unread {
+		lp.unread = false
+		lp.repeat = true
+		return true
+	}
 	return lp.scanner.Scan()
 }
 
+// Unread causes the next Scan to return the current line again.
+func (lp *linescanner) Unread() {
+	lp.unread = true
+}
+
 func (lp *linescanner) Text() string {
+	if lp.repeat {
+		lp.repeat = false
+		return lp.line
+	}
+
 	var line string
 	if lp.unsafe {
 		if lbytes := lp.scanner.Bytes(); len(lbytes) > 0 {
@@ -52,6 +71,7 @@ func (lp *linescanner) Text() string {
 		line = lp.scanner.Text()
 	}
 	lp.lineCount++
+	lp.line = line
 	return line
 }
 
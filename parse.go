@@ -1,59 +1,131 @@
 package ledger
 
 import (
+	"cmp"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 	"unicode"
+	"unicode/utf8"
 
-	"github.com/alfredxing/calc/compute"
 	"github.com/howeyc/ledger/decimal"
 	date "github.com/joyt/godate"
 )
 
+// ParseOption changes how ledger files are parsed.
+type ParseOption func(*parseOptions)
+
+type parseOptions struct {
+	strict bool
+
+	// numberFormat of the amounts in the journal
+	numberFormat decimal.NumberFormat
+
+	// stream is called with each transaction as it is parsed, instead of
+	// collecting the transactions of each file. Included files are parsed
+	// in place of the include directive.
+	stream func(trans *Transaction) (stop bool)
+
+	// cache holds the results of included files from an earlier parse,
+	// which are used again for files that have not changed.
+	cache *includeCache
+}
+
+// WithStrict returns an option that reports an error for every posting to an
+// account not declared with an account directive, and every amount in a
+// commodity not declared with a commodity directive.
+func WithStrict() ParseOption {
+	return func(po *parseOptions) {
+		po.strict = true
+	}
+}
+
+// WithNumberFormat returns an option that reads amounts in the number format
+// f, such as "1.234,56". Negative amounts are written with a minus sign, as
+// an amount in parentheses is an expression.
+func WithNumberFormat(f decimal.NumberFormat) ParseOption {
+	return func(po *parseOptions) {
+		po.numberFormat = f
+		po.numberFormat.Parens = false
+	}
+}
+
 // ParseLedgerFile parses a ledger file and returns a list of Transactions.
-func ParseLedgerFile(filename string) (generalLedger []*Transaction, err error) {
+func ParseLedgerFile(filename string, opts ...ParseOption) (generalLedger []*Transaction, err error) {
+	journal, err := ParseJournalFile(filename, opts...)
+	if journal == nil {
+		return nil, err
+	}
+	return journal.Transactions, err
+}
+
+// ParseJournalFile parses a ledger file and returns the Journal of all
+// transactions and directives. Postings to an alias of a declared account are
+// renamed to the declared account.
+func ParseJournalFile(filename string, opts ...ParseOption) (journal *Journal, err error) {
 	ifile, ierr := os.Open(filename)
 	if ierr != nil {
 		return nil, ierr
 	}
 	defer ifile.Close()
-	var mu sync.Mutex
-	parseLedger(filename, ifile, func(t []*Transaction, e error) (stop bool) {
-		if e != nil {
-			err = e
-			stop = true
-			return
-		}
+	return parseJournal(filename, ifile, opts)
+}
 
-		mu.Lock()
-		generalLedger = append(generalLedger, t...)
-		mu.Unlock()
-		return
-	})
+// ParseLedger parses a ledger file and returns a list of Transactions.
+func ParseLedger(ledgerReader io.Reader, opts ...ParseOption) (generalLedger []*Transaction, err error) {
+	journal, err := parseJournal("", ledgerReader, opts)
+	return journal.Transactions, err
+}
 
-	return
+// ParseJournal parses a ledger file and returns the Journal of all
+// transactions and directives. Postings to an alias of a declared account are
+// renamed to the declared account.
+func ParseJournal(ledgerReader io.Reader, opts ...ParseOption) (journal *Journal, err error) {
+	return parseJournal("", ledgerReader, opts)
 }
 
-// ParseLedger parses a ledger file and returns a list of Transactions.
-func ParseLedger(ledgerReader io.Reader) (generalLedger []*Transaction, err error) {
-	parseLedger("", ledgerReader, func(t []*Transaction, e error) (stop bool) {
+func parseJournal(filename string, ledgerReader io.Reader, opts []ParseOption) (journal *Journal, err error) {
+	var po parseOptions
+	for _, opt := range opts {
+		opt(&po)
+	}
+
+	journal = &Journal{}
+	var refs []postingRef
+	var errs []error
+	parseLedger(filename, ledgerReader, &po, nil, nil, func(r *parseResult, e error) (stop bool) {
 		if e != nil {
-			err = e
-			stop = true
+			errs = append(errs, e)
 			return
 		}
 
-		generalLedger = append(generalLedger, t...)
+		journal.add(r)
+		refs = append(refs, r.postingRefs...)
+		if po.cache != nil {
+			po.cache.sources.add(r.sources)
+		}
 		return
 	})
+	journal.applyAliases()
+	if po.strict {
+		if strictErr := journal.checkDeclared(refs); strictErr != nil {
+			errs = append(errs, strictErr)
+		}
+	}
+	// balances are checked over the transactions that did parse
+	if balErr := journal.checkBalances(refs); balErr != nil {
+		errs = append(errs, balErr)
+	}
 
-	return
+	return journal, errors.Join(errs...)
 }
 
 // ParseLedgerAsync parses a ledger file and returns a Transaction and error channels .
@@ -62,11 +134,11 @@ func ParseLedgerAsync(ledgerReader io.Reader) (c chan *Transaction, e chan error
 	e = make(chan error)
 
 	go func() {
-		parseLedger("", ledgerReader, func(tlist []*Transaction, err error) (stop bool) {
+		parseLedger("", ledgerReader, &parseOptions{}, nil, nil, func(r *parseResult, err error) (stop bool) {
 			if err != nil {
 				e <- err
 			} else {
-				for _, t := range tlist {
+				for _, t := range r.transactions {
 					c <- t
 				}
 			}
@@ -80,8 +152,210 @@ func ParseLedgerAsync(ledgerReader io.Reader) (c chan *Transaction, e chan error
 	return c, e
 }
 
+// ParseTransactions returns an iterator over the transactions of a ledger
+// file, in the order they are written. Included files are parsed in place of
+// the include directive. An error parsing a transaction is yielded with a nil
+// transaction, and parsing carries on unless the loop stops.
+//
+// Parsing stops when the loop stops or ctx is done, in which case ctx.Err()
+// is the last error yielded. Transactions are not kept once yielded, so
+// account aliases, strict checks, and balance assertions, which need the
+// whole journal, are not applied.
+func ParseTransactions(ctx context.Context, ledgerReader io.Reader) iter.Seq2[*Transaction, error] {
+	return parseTransactions(ctx, "", ledgerReader)
+}
+
+// ParseTransactionsFile returns an iterator over the transactions of a ledger
+// file, the same as ParseTransactions.
+func ParseTransactionsFile(ctx context.Context, filename string) iter.Seq2[*Transaction, error] {
+	return func(yield func(*Transaction, error) bool) {
+		ifile, err := os.Open(filename)
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		defer ifile.Close()
+		parseTransactions(ctx, filename, ifile)(yield)
+	}
+}
+
+func parseTransactions(ctx context.Context, filename string, ledgerReader io.Reader) iter.Seq2[*Transaction, error] {
+	return func(yield func(*Transaction, error) bool) {
+		stopped := false
+		send := func(trans *Transaction, err error) (stop bool) {
+			if stopped {
+				return true
+			}
+			if cerr := ctx.Err(); cerr != nil {
+				yield(nil, cerr)
+				stopped = true
+			} else {
+				stopped = !yield(trans, err)
+			}
+			return stopped
+		}
+
+		po := parseOptions{stream: func(trans *Transaction) (stop bool) {
+			return send(trans, nil)
+		}}
+		parseLedger(filename, ledgerReader, &po, nil, nil, func(_ *parseResult, err error) (stop bool) {
+			if err != nil {
+				return send(nil, err)
+			}
+			return stopped
+		})
+	}
+}
+
+// parseResult holds everything parsed from a single file.
+type parseResult struct {
+	transactions []*Transaction
+	prices       []Price
+	accounts     []*AccountDeclaration
+	commodities  []*CommodityDeclaration
+	periodic     []*PeriodicTransaction
+	automated    []*AutomatedTransaction
+	postingRefs  []postingRef
+
+	// sources are the files read and include patterns matched, only kept
+	// when parsing with a cache
+	sources sources
+}
+
+// includedResult is a result of an included file, kept until the results
+// before the include directive are passed on. In the cache, include is set
+// in place of a result for each include directive of the file.
+type includedResult struct {
+	result  *parseResult
+	err     error
+	include *includeDirective
+}
+
+// includeDirective is the files matched by an include directive, and the
+// automated transactions that apply to them.
+type includeDirective struct {
+	paths    []string
+	autos    []*AutomatedTransaction
+	cycleErr error
+}
+
+// postingRef is the location of a posting, kept for strict checking and
+// balance assertions.
+type postingRef struct {
+	filename string
+	line     int
+	column   int
+	// account is empty for the refs of the cost commodities of a posting
+	account   string
+	commodity string
+	posting   *Account
+
+	// assignment is set for balance assignments, postings with a balance
+	// assertion and no amount.
+	assignment bool
+}
+
+// parseError returns an error of kind at the posting.
+func (ref *postingRef) parseError(kind ErrorKind, err error) *ParseError {
+	return &ParseError{File: ref.filename, Line: ref.line, Column: ref.column, Kind: kind, Err: err}
+}
+
+// add adds the parse results of a file to the journal.
+func (j *Journal) add(r *parseResult) {
+	j.Transactions = append(j.Transactions, r.transactions...)
+	for _, p := range r.prices {
+		j.Prices.Add(p)
+	}
+	j.Accounts = append(j.Accounts, r.accounts...)
+	j.Commodities = append(j.Commodities, r.commodities...)
+	j.Periodic = append(j.Periodic, r.periodic...)
+	j.Automated = append(j.Automated, r.automated...)
+}
+
+// checkDeclared returns an error for each posting with an account or
+// commodity that is not declared.
+func (j *Journal) checkDeclared(refs []postingRef) error {
+	accounts := make(map[string]bool)
+	for _, decl := range j.Accounts {
+		accounts[decl.Name] = true
+		for _, alias := range decl.Aliases {
+			accounts[alias] = true
+		}
+	}
+	commodities := map[string]bool{"": true}
+	for _, decl := range j.Commodities {
+		commodities[decl.Name] = true
+		for _, alias := range decl.Aliases {
+			commodities[alias] = true
+		}
+	}
+
+	slices.SortStableFunc(refs, func(a, b postingRef) int {
+		return cmp.Or(
+			strings.Compare(a.filename, b.filename),
+			cmp.Compare(a.line, b.line),
+		)
+	})
+
+	var errs []error
+	for _, ref := range refs {
+		if len(ref.account) > 0 && !accounts[ref.account] {
+			errs = append(errs, ref.parseError(UndeclaredAccountError, fmt.Errorf("undeclared account(%s)", ref.account)))
+		}
+		if !commodities[ref.commodity] {
+			errs = append(errs, ref.parseError(UndeclaredCommodityError, fmt.Errorf("undeclared commodity(%s)", ref.commodity)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applyAliases renames postings to an alias of a declared account (or
+// commodity) to the name of the declared account (or commodity).
+func (j *Journal) applyAliases() {
+	aliases := make(map[string]string)
+	for _, decl := range j.Accounts {
+		for _, alias := range decl.Aliases {
+			aliases[alias] = decl.Name
+		}
+	}
+	commodityAliases := make(map[string]string)
+	for _, decl := range j.Commodities {
+		for _, alias := range decl.Aliases {
+			commodityAliases[alias] = decl.Name
+		}
+	}
+	if len(aliases) == 0 && len(commodityAliases) == 0 {
+		return
+	}
+
+	entries := slices.Clip(j.Transactions)
+	for _, periodic := range j.Periodic {
+		entries = append(entries, &periodic.Transaction)
+	}
+	for _, trans := range entries {
+		for i := range trans.AccountChanges {
+			accChange := &trans.AccountChanges[i]
+			if name, found := aliases[accChange.Name]; found {
+				accChange.Name = name
+			}
+			if name, found := commodityAliases[accChange.Commodity]; found {
+				accChange.Commodity = name
+			}
+			if accChange.Cost != nil {
+				if name, found := commodityAliases[accChange.Cost.Commodity]; found {
+					accChange.Cost.Commodity = name
+				}
+				if name, found := commodityAliases[accChange.Cost.LotCommodity]; found {
+					accChange.Cost.LotCommodity = name
+				}
+			}
+		}
+	}
+}
+
 type parser struct {
 	scanner *linescanner
+	options *parseOptions
 
 	comments   []string
 	dateLayout string
@@ -94,31 +368,136 @@ type parser struct {
 	ctIdx        int
 	postings     []Account
 	cpIdx        int
+
+	realBals    balanceGroup
+	virtualBals balanceGroup
+	postingRefs []postingRef
+
+	autos []*AutomatedTransaction
 }
 
-const preAllocSize = 100000
+// balanceGroup is the running sum of the postings within a transaction that
+// must balance together, along with the postings to place extra balance in.
+type balanceGroup struct {
+	commBals      []commodityBalance
+	numEmpty      int
+	emptyAccIndex int
+}
+
+func (g *balanceGroup) reset() {
+	g.commBals = g.commBals[:0]
+	g.numEmpty = 0
+	g.emptyAccIndex = 0
+}
+
+// add adds the posting at accIndex to the group.
+func (g *balanceGroup) add(posting *Account, accIndex int) (err error) {
+	switch {
+	case posting.Balance.IsZero():
+		g.numEmpty++
+		g.emptyAccIndex = accIndex
+	case posting.Cost != nil:
+		g.commBals, err = addBalance(g.commBals, posting.Cost.Commodity, posting.Cost.Total)
+	default:
+		g.commBals, err = addBalance(g.commBals, posting.Commodity, posting.Balance)
+	}
+	return err
+}
+
+// commodityBalance is the running sum of a single commodity within a
+// transaction.
+type commodityBalance struct {
+	commodity string
+	balance   decimal.Decimal
+}
+
+// Transactions and postings are allocated preAllocMinSize at a time at first,
+// twice as many each time after, up to preAllocMaxSize, so small (included)
+// files do not hold on to more than needed.
+const preAllocMinSize = 1000
+const preAllocMaxSize = 100000
 const preAllocWarn = 10
 
 func (p *parser) init() {
-	p.transactions = make([]Transaction, preAllocSize)
-	p.postings = make([]Account, preAllocSize*3)
-	p.ctIdx = 0
-	p.cpIdx = 0
+	p.alloc(preAllocMinSize)
 }
 
 func (p *parser) grow() {
 	if len(p.transactions)-p.ctIdx < preAllocWarn ||
 		len(p.postings)-p.cpIdx < (preAllocWarn*3) {
-		p.init()
+		size := preAllocMinSize
+		if p.options.stream == nil {
+			// streamed transactions are not all kept
+			size = min(len(p.transactions)*2, preAllocMaxSize)
+		}
+		p.alloc(size)
+	}
+}
+
+// reservePosting makes room for the posting at accIndex of the transaction
+// being parsed, moving its postings to a larger slab when the slab is full.
+func (p *parser) reservePosting(accIndex int) {
+	if p.cpIdx+accIndex < len(p.postings) {
+		return
+	}
+	moved := p.postings[p.cpIdx : p.cpIdx+accIndex]
+	p.postings = make([]Account, max(len(p.postings), accIndex*2))
+	copy(p.postings, moved)
+	p.cpIdx = 0
+
+	// refs are in the order of their postings
+	j := 0
+	for i := range p.postingRefs {
+		for j < len(moved) && p.postingRefs[i].posting != &moved[j] {
+			j++
+		}
+		if j < len(moved) {
+			p.postingRefs[i].posting = &p.postings[j]
+		}
 	}
 }
 
-func parseLedger(filename string, ledgerReader io.Reader, callback func(t []*Transaction, err error) (stop bool)) (stop bool) {
+func (p *parser) alloc(size int) {
+	p.transactions = make([]Transaction, size)
+	p.postings = make([]Account, size*3)
+	p.ctIdx = 0
+	p.cpIdx = 0
+}
+
+// parseLedger parses a ledger file, calling callback with the results of each
+// file, in the order they are written. Automated transactions (autos) of the
+// including file apply to the transactions of the included file. Includes are
+// the absolute paths of the files including this one, which may not be
+// included again.
+func parseLedger(filename string, ledgerReader io.Reader, options *parseOptions, autos []*AutomatedTransaction, includes []string, callback func(r *parseResult, err error) (stop bool)) (stop bool) {
+	if abspath, err := filepath.Abs(filename); len(filename) > 0 && err == nil {
+		includes = append(slices.Clip(includes), abspath)
+	}
+
+	cached := options.cache != nil && len(filename) > 0
+	if cached {
+		if results, found := options.cache.lookup(filename, autos); found {
+			return replayResults(results, options, includes, callback)
+		}
+	}
+
 	var lp parser
+	lp.options = options
 	lp.init()
+	lp.autos = autos
 	lp.scanner = newLineScanner(filename, ledgerReader)
 
-	var tlist []*Transaction
+	result := &parseResult{}
+	// own results of the file, and its include directives, kept in the cache
+	var own []includedResult
+	emit := callback
+	if cached {
+		result.sources.addFile(filename)
+		emit = func(r *parseResult, err error) (stop bool) {
+			own = append(own, includedResult{r, err, nil})
+			return callback(r, err)
+		}
+	}
 
 	for lp.scanner.Scan() {
 		// remove heading and tailing space from the line
@@ -142,62 +521,318 @@ func parseLedger(filename string, ledgerReader io.Reader, callback func(t []*Tra
 
 		before, after, split := strings.Cut(trimmedLine, " ")
 		if !split {
-			if callback(nil, fmt.Errorf("%s:%d: unable to parse transaction: %w", lp.scanner.Name(), lp.scanner.LineNumber(),
-				fmt.Errorf("unable to parse payee line: %s", trimmedLine))) {
+			if emit(nil, lp.parseError(TransactionError, fmt.Errorf("unable to parse transaction: %w",
+				fmt.Errorf("unable to parse payee line: %s", trimmedLine)))) {
 				return true
 			}
-			if len(currentComment) > 0 {
-				lp.comments = append(lp.comments, currentComment)
-			}
+			lp.skipEntry()
 			continue
 		}
 		switch before {
 		case "account":
-			lp.skipAccount()
+			decl := lp.parseAccount(after)
+			if len(currentComment) > 0 {
+				decl.Comments = append(decl.Comments, currentComment)
+			}
+			result.accounts = append(result.accounts, decl)
+		case "commodity":
+			decl := lp.parseCommodity(after)
+			if len(currentComment) > 0 {
+				decl.Comments = append(decl.Comments, currentComment)
+			}
+			result.commodities = append(result.commodities, decl)
+		case "=":
+			auto, autoErr := lp.parseAutomated(after)
+			if autoErr != nil {
+				if emit(nil, lp.parseError(AutomatedTransactionError, fmt.Errorf("unable to parse automated transaction: %w", autoErr))) {
+					return true
+				}
+				lp.skipEntry()
+				continue
+			}
+			lp.autos = append(lp.autos, auto)
+			result.automated = append(result.automated, auto)
+		case "~":
+			periodic, periodicErr := lp.parsePeriodic(after, currentComment)
+			if periodicErr != nil {
+				if emit(nil, lp.parseError(PeriodicTransactionError, fmt.Errorf("unable to parse periodic transaction: %w", periodicErr))) {
+					return true
+				}
+				lp.skipEntry()
+				continue
+			}
+			result.periodic = append(result.periodic, periodic)
+			result.postingRefs = append(result.postingRefs, lp.postingRefs...)
+		case "P":
+			price, priceErr := lp.parsePrice(after)
+			if priceErr != nil {
+				if emit(nil, lp.parseError(PriceError, fmt.Errorf("unable to parse price: %w", priceErr))) {
+					return true
+				}
+				continue
+			}
+			result.prices = append(result.prices, price)
 		case "include":
-			paths, _ := filepath.Glob(filepath.Join(filepath.Dir(lp.scanner.Name()), after))
+			paths, _ := includePaths(lp.scanner.Name(), after)
+			if options.cache != nil {
+				result.sources.addInclude(lp.scanner.Name(), after, paths)
+			}
 			if len(paths) < 1 {
-				callback(nil, fmt.Errorf("%s:%d: unable to include file(%s): %w", lp.scanner.Name(), lp.scanner.LineNumber(), after, errors.New("not found")))
+				if emit(nil, lp.parseError(IncludeError, fmt.Errorf("unable to include file(%s): %w", after, errors.New("not found")))) {
+					return true
+				}
+				continue
+			}
+			inc := &includeDirective{
+				paths:    paths,
+				autos:    slices.Clip(lp.autos),
+				cycleErr: lp.parseError(IncludeError, fmt.Errorf("unable to include file(%s): %w", after, errors.New("include cycle"))),
+			}
+			if emit(result, nil) {
 				return true
 			}
-			var wg sync.WaitGroup
-			for _, incpath := range paths {
-				wg.Add(1)
-				go func(ipath string) {
-					ifile, _ := os.Open(ipath)
-					defer ifile.Close()
-					if parseLedger(ipath, ifile, callback) {
-						stop = true
-					}
-					wg.Done()
-				}(incpath)
+			result = &parseResult{}
+			if cached {
+				own = append(own, includedResult{include: inc})
 			}
-			wg.Wait()
-			if stop {
-				return stop
+			if parseIncluded(inc, options, includes, callback) {
+				return true
 			}
 		default:
 			trans, transErr := lp.parseTransaction(before, after, currentComment)
 			if transErr != nil {
-				if callback(nil, fmt.Errorf("%s:%d: unable to parse transaction: %w", lp.scanner.Name(), lp.scanner.LineNumber(), transErr)) {
+				if emit(nil, lp.parseError(TransactionError, fmt.Errorf("unable to parse transaction: %w", transErr))) {
 					return true
 				}
+				lp.skipEntry()
 				continue
 			}
-			tlist = append(tlist, trans)
+			if options.stream != nil {
+				if options.stream(trans) {
+					return true
+				}
+				continue
+			}
+			result.transactions = append(result.transactions, trans)
+			result.postingRefs = append(result.postingRefs, lp.postingRefs...)
+		}
+	}
+	emit(result, nil)
+	if cached {
+		options.cache.store(filename, autos, own)
+	}
+	return false
+}
+
+// parseIncluded parses the files of an include directive, calling callback
+// with the results of each file in order. Files already including this one
+// are skipped.
+func parseIncluded(inc *includeDirective, options *parseOptions, includes []string, callback func(r *parseResult, err error) (stop bool)) (stop bool) {
+	paths := slices.DeleteFunc(slices.Clone(inc.paths), func(incpath string) bool {
+		abspath, _ := filepath.Abs(incpath)
+		return slices.Contains(includes, abspath)
+	})
+	if len(paths) < 1 {
+		return callback(nil, inc.cycleErr)
+	}
+	if options.stream != nil {
+		for _, incpath := range paths {
+			ifile, _ := os.Open(incpath)
+			stop = parseLedger(incpath, ifile, options, inc.autos, includes, callback)
+			ifile.Close()
+			if stop {
+				return stop
+			}
+		}
+		return false
+	}
+
+	// parse the included files in parallel, then pass on the results of each
+	// included file, in order
+	included := make([][]includedResult, len(paths))
+	var wg sync.WaitGroup
+	for i, incpath := range paths {
+		wg.Go(func() {
+			ifile, _ := os.Open(incpath)
+			defer ifile.Close()
+			parseLedger(incpath, ifile, options, inc.autos, includes, func(r *parseResult, err error) (stop bool) {
+				included[i] = append(included[i], includedResult{r, err, nil})
+				return false
+			})
+		})
+	}
+	wg.Wait()
+	for _, results := range included {
+		for _, ir := range results {
+			if callback(ir.result, ir.err) {
+				return true
+			}
 		}
 	}
-	callback(tlist, nil)
 	return false
 }
 
-func (lp *parser) skipAccount() {
+// replayResults passes on the results of a file kept in the cache, parsing
+// the files of its include directives.
+func replayResults(results []includedResult, options *parseOptions, includes []string, callback func(r *parseResult, err error) (stop bool)) (stop bool) {
+	for _, ir := range results {
+		if ir.include != nil {
+			stop = parseIncluded(ir.include, options, includes, callback)
+		} else {
+			stop = callback(ir.result, ir.err)
+		}
+		if stop {
+			return stop
+		}
+	}
+	return false
+}
+
+// parseSubDirectives parses the indented sub-directives that follow a
+// directive, calling handle with each sub-directive and its value. Returns
+// the comments of the sub-directive lines.
+func (lp *parser) parseSubDirectives(handle func(directive, value string)) (comments []string) {
 	for lp.scanner.Scan() {
-		// Read until blank line (ignore all sub-directives)
-		if len(lp.scanner.Text()) == 0 {
-			return
+		line := lp.scanner.Text()
+
+		// Read until blank line, or a line that is not indented which is
+		// the start of something else
+		if len(strings.TrimSpace(line)) == 0 {
+			break
+		}
+		if r, _ := utf8.DecodeRuneInString(line); !unicode.IsSpace(r) {
+			lp.scanner.Unread()
+			break
+		}
+
+		if commentIdx := strings.Index(line, ";"); commentIdx >= 0 {
+			comments = append(comments, line[commentIdx:])
+			line = line[:commentIdx]
+		}
+
+		directive, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		handle(directive, strings.TrimSpace(value))
+	}
+	return comments
+}
+
+// parseAccount parses an account directive and its sub-directives. Unknown
+// sub-directives are ignored.
+func (lp *parser) parseAccount(name string) *AccountDeclaration {
+	decl := &AccountDeclaration{Name: strings.TrimSpace(name)}
+	decl.Comments = lp.parseSubDirectives(func(directive, value string) {
+		switch directive {
+		case "note":
+			if len(decl.Note) > 0 {
+				decl.Note += "\n"
+			}
+			decl.Note += value
+		case "alias":
+			decl.Aliases = append(decl.Aliases, value)
+		case "default":
+			decl.Default = true
+		case "assert":
+			decl.Asserts = append(decl.Asserts, value)
+		case "check":
+			decl.Checks = append(decl.Checks, value)
+		}
+	})
+	return decl
+}
+
+// parseCommodity parses a commodity directive and its sub-directives.
+// Unknown sub-directives are ignored.
+func (lp *parser) parseCommodity(name string) *CommodityDeclaration {
+	decl := &CommodityDeclaration{Name: strings.TrimSpace(name)}
+	decl.Comments = lp.parseSubDirectives(func(directive, value string) {
+		switch directive {
+		case "note":
+			if len(decl.Note) > 0 {
+				decl.Note += "\n"
+			}
+			decl.Note += value
+		case "format":
+			decl.Format = value
+		case "alias":
+			decl.Aliases = append(decl.Aliases, value)
+		case "default":
+			decl.Default = true
+		}
+	})
+	return decl
+}
+
+// parsePeriodic parses a periodic transaction, which is a period expression
+// (PERIOD [from DATE] [to DATE]) followed by an optional description after
+// two spaces, and postings.
+func (lp *parser) parsePeriodic(line, comment string) (periodic *PeriodicTransaction, err error) {
+	periodic = &PeriodicTransaction{}
+
+	expr, description := line, ""
+	if iSep := strings.Index(line, "  "); iSep >= 0 {
+		expr, description = line[:iSep], strings.TrimSpace(line[iSep:])
+	} else if iSep := strings.IndexByte(line, '\t'); iSep >= 0 {
+		expr, description = line[:iSep], strings.TrimSpace(line[iSep:])
+	}
+
+	// postings are parsed first so that they are consumed even when the
+	// period expression is bad
+	trans, terr := lp.parseEntry(description, comment)
+	if terr != nil {
+		return nil, terr
+	}
+	periodic.Transaction = *trans
+
+	fields := strings.Fields(expr)
+	if len(fields) == 0 {
+		return nil, errors.New("missing period")
+	}
+	for _, per := range []Period{PeriodDay, PeriodWeek, Period2Week, PeriodMonth, Period2Month, PeriodQuarter, PeriodSemiYear, PeriodYear} {
+		if strings.EqualFold(fields[0], string(per)) {
+			periodic.Period = per
 		}
 	}
+	if len(periodic.Period) == 0 {
+		return nil, fmt.Errorf("unknown period(%s)", fields[0])
+	}
+	for fields = fields[1:]; len(fields) > 0; fields = fields[2:] {
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("missing date after %s", fields[0])
+		}
+		pdate, derr := lp.parseDate(fields[1])
+		if derr != nil {
+			return nil, derr
+		}
+		switch strings.ToLower(fields[0]) {
+		case "from":
+			periodic.Start = pdate
+		case "to", "until":
+			periodic.End = pdate
+		default:
+			return nil, fmt.Errorf("unable to parse period expression: %s", expr)
+		}
+	}
+
+	return periodic, nil
+}
+
+// parsePrice parses the rest of a P directive line, which is a date,
+// commodity, and the price of the commodity.
+func (lp *parser) parsePrice(line string) (price Price, err error) {
+	dateString, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+	commodity, amount, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	if !isCommodity(commodity) {
+		return price, fmt.Errorf("invalid commodity(%s)", commodity)
+	}
+
+	if price.Date, err = lp.parseDate(dateString); err != nil {
+		return price, err
+	}
+	price.Commodity = commodity
+	if price.Amount, price.PriceCommodity, err = parseCommodityAmount(lp.options.numberFormat, amount); err != nil {
+		return price, fmt.Errorf("invalid amount(%s): %w", strings.TrimSpace(amount), err)
+	}
+	return price, nil
 }
 
 func (lp *parser) parseDate(dateString string) (transDate time.Time, err error) {
@@ -224,20 +859,236 @@ func (lp *parser) parseDate(dateString string) (transDate time.Time, err error)
 	return
 }
 
+// parseError returns an error of kind at the current line.
+func (lp *parser) parseError(kind ErrorKind, err error) *ParseError {
+	return &ParseError{
+		File:   lp.scanner.Name(),
+		Line:   lp.scanner.LineNumber(),
+		Column: textColumn(lp.scanner.line),
+		Kind:   kind,
+		Err:    err,
+	}
+}
+
+// skipEntry skips the rest of the transaction after an error, up to the next
+// blank line, so that parsing carries on with the next entry.
+func (lp *parser) skipEntry() {
+	if len(strings.TrimSpace(lp.scanner.line)) == 0 {
+		return
+	}
+	for lp.scanner.Scan() {
+		if len(strings.TrimSpace(lp.scanner.Text())) == 0 {
+			return
+		}
+	}
+}
+
+// position returns the position of the current line.
+func (lp *parser) position() Position {
+	line := lp.scanner.LineNumber()
+	return Position{Filename: lp.scanner.Name(), Line: line, EndLine: line}
+}
+
+// statusMark returns the status for a status mark character.
+func statusMark(c byte) (status Status, found bool) {
+	switch c {
+	case '*':
+		return Cleared, true
+	case '!':
+		return Pending, true
+	}
+	return Uncleared, false
+}
+
+// parseStatusCode splits the optional status mark and (CODE) from the start
+// of the payee.
+func parseStatusCode(payeeString string) (status Status, code, payee string) {
+	payee = strings.TrimSpace(payeeString)
+	if len(payee) > 1 && unicode.IsSpace(rune(payee[1])) {
+		if markStatus, found := statusMark(payee[0]); found {
+			status = markStatus
+			payee = strings.TrimSpace(payee[1:])
+		}
+	}
+	if rest, found := strings.CutPrefix(payee, "("); found {
+		if codeString, after, closed := strings.Cut(rest, ")"); closed {
+			code = strings.TrimSpace(codeString)
+			payee = strings.TrimSpace(after)
+		}
+	}
+	return status, code, payee
+}
+
+// addBalance adds amt to the sum of commodity in cbs.
+func addBalance(cbs []commodityBalance, commodity string, amt decimal.Decimal) ([]commodityBalance, error) {
+	for i := range cbs {
+		if cbs[i].commodity == commodity {
+			sum, err := cbs[i].balance.CheckedAdd(amt)
+			if err != nil {
+				return cbs, fmt.Errorf("unable to balance transaction: %w", err)
+			}
+			cbs[i].balance = sum
+			return cbs, nil
+		}
+	}
+	return append(cbs, commodityBalance{commodity: commodity, balance: amt}), nil
+}
+
+// balance places the extra balance of the group in its empty posting, for
+// the transaction being parsed with accIndex postings. Returns the new number
+// of postings.
+func (lp *parser) balance(group *balanceGroup, accIndex int) (int, error) {
+	// Only commodities that do not sum to zero need balancing
+	unbalanced := group.commBals[:0]
+	for _, cb := range group.commBals {
+		if !cb.balance.IsZero() {
+			unbalanced = append(unbalanced, cb)
+		}
+	}
+	if len(unbalanced) == 0 {
+		return accIndex, nil
+	}
+
+	switch group.numEmpty {
+	case 0:
+		return accIndex, errors.New("unable to balance transaction: no empty account to place extra balance")
+	case 1:
+		// If there is a single empty account, then it is obvious where to
+		// place the remaining balance. Each unbalanced commodity after the
+		// first gets an additional posting to the same account.
+		emptyPosting := lp.postings[lp.cpIdx+group.emptyAccIndex]
+		for i, cb := range unbalanced {
+			pIdx := lp.cpIdx + group.emptyAccIndex
+			if i > 0 {
+				lp.reservePosting(accIndex)
+				pIdx = lp.cpIdx + accIndex
+				lp.postings[pIdx] = emptyPosting
+				accIndex++
+			}
+			lp.postings[pIdx].Balance = cb.balance.Neg()
+			lp.postings[pIdx].Commodity = cb.commodity
+		}
+	default:
+		return accIndex, errors.New("unable to balance transaction: more than one account empty")
+	}
+	return accIndex, nil
+}
+
+// splitPostingKind returns the kind of a posting from the account name, which
+// is in parentheses for a virtual posting, or brackets for a balanced virtual
+// posting. The returned line has the parentheses or brackets replaced by
+// spaces after the name.
+func splitPostingKind(line string) (kind PostingKind, rest string, err error) {
+	postingLine := strings.TrimLeftFunc(line, unicode.IsSpace)
+	if len(postingLine) == 0 {
+		return RealPosting, line, nil
+	}
+	var closer string
+	switch postingLine[0] {
+	case '(':
+		kind = VirtualPosting
+		closer = ")"
+	case '[':
+		kind = BalancedVirtualPosting
+		closer = "]"
+	default:
+		return RealPosting, line, nil
+	}
+	name, after, closed := strings.Cut(postingLine[1:], closer)
+	if !closed {
+		return kind, line, fmt.Errorf("unable to parse virtual account(%s): missing %s", postingLine, closer)
+	}
+	return kind, name + "  " + after, nil
+}
+
+// cutAmount splits a posting line at the first tab or two spaces after the
+// account name. It returns false if there is nothing after the account name.
+func cutAmount(s string) (name, amount string, found bool) {
+	s = strings.TrimSpace(s)
+	iSep := strings.IndexByte(s, '\t')
+	if iSpaces := strings.Index(s, "  "); iSpaces >= 0 && (iSep < 0 || iSpaces < iSep) {
+		iSep = iSpaces
+	}
+	if iSep < 0 {
+		return s, "", false
+	}
+	return s[:iSep], strings.TrimSpace(s[iSep:]), true
+}
+
+// splitQuantity splits a posting that ends with a number into account name
+// and number. As account names can contain spaces, the number must be
+// separated from the account by a tab or at least two spaces.
+func splitQuantity(f decimal.NumberFormat, s string) (name string, quantity decimal.Decimal, ok bool) {
+	s = strings.TrimRightFunc(s, unicode.IsSpace)
+	iSpace := strings.LastIndexFunc(s, unicode.IsSpace)
+	if iSpace < 1 || (s[iSpace] != '\t' && s[iSpace-1] != ' ') {
+		return "", decimal.Zero, false
+	}
+	quantity, err := f.Parse(s[iSpace+1:])
+	if err != nil {
+		return "", decimal.Zero, false
+	}
+	return strings.TrimSpace(s[:iSpace]), quantity, true
+}
+
 func (lp *parser) parseTransaction(dateString, payeeString, payeeComment string) (trans *Transaction, err error) {
+	dateString, effectiveString, hasEffective := strings.Cut(dateString, "=")
 	transDate, derr := lp.parseDate(dateString)
 	if derr != nil {
 		return nil, derr
 	}
+	var effectiveDate time.Time
+	if hasEffective {
+		if effectiveDate, derr = lp.parseDate(effectiveString); derr != nil {
+			return nil, derr
+		}
+	}
+
+	trans, err = lp.parseEntry(payeeString, payeeComment)
+	if err != nil {
+		return nil, err
+	}
+	trans.Date = transDate
+	trans.EffectiveDate = effectiveDate
+	return trans, nil
+}
+
+// parseEffectiveDate returns the effective date ([=DATE]) in the comment of a
+// posting, or a zero time if there is none.
+func (lp *parser) parseEffectiveDate(comment string) (time.Time, error) {
+	_, after, found := strings.Cut(comment, "[=")
+	if !found {
+		return time.Time{}, nil
+	}
+	dateString, _, closed := strings.Cut(after, "]")
+	if !closed {
+		return time.Time{}, fmt.Errorf("unable to parse effective date(%s): missing ]", comment)
+	}
+	return lp.parseDate(strings.TrimSpace(dateString))
+}
 
-	transBal := decimal.Zero
-	var numEmpty int
-	var emptyAccIndex int
+// parseEntry parses the payee and postings of a transaction, or of a
+// periodic transaction.
+func (lp *parser) parseEntry(payeeString, payeeComment string) (trans *Transaction, err error) {
+	status, code, payeeString := parseStatusCode(payeeString)
+
+	lp.postingRefs = lp.postingRefs[:0]
+
+	var numAssigned int
 	var accIndex int
 
+	lp.realBals.reset()
+	lp.virtualBals.reset()
+
+	position := lp.position()
+
 	for lp.scanner.Scan() {
 		trimmedLine := lp.scanner.Text()
 
+		lp.reservePosting(accIndex)
+		posting := &lp.postings[lp.cpIdx+accIndex]
+		*posting = Account{Line: lp.scanner.LineNumber()}
+
 		// handle comments
 		if commentIdx := strings.Index(trimmedLine, ";"); commentIdx >= 0 {
 			currentComment := trimmedLine[commentIdx:]
@@ -245,35 +1096,127 @@ func (lp *parser) parseTransaction(dateString, payeeString, payeeComment string)
 			trimmedLine = strings.TrimSpace(trimmedLine)
 			if len(trimmedLine) == 0 {
 				lp.comments = append(lp.comments, currentComment)
+				position.EndLine = posting.Line
 				continue
 			}
-			lp.postings[lp.cpIdx+accIndex].Comment = currentComment
+			posting.Comment = currentComment
+			posting.Tags = parseTags(currentComment, nil)
+			if posting.EffectiveDate, err = lp.parseEffectiveDate(currentComment); err != nil {
+				return nil, err
+			}
 		}
 
 		if len(trimmedLine) == 0 {
 			break
 		}
+		position.EndLine = posting.Line
+
+		// status mark before the account name
+		if postingLine := strings.TrimLeftFunc(trimmedLine, unicode.IsSpace); len(postingLine) > 1 && unicode.IsSpace(rune(postingLine[1])) {
+			if postingStatus, found := statusMark(postingLine[0]); found {
+				posting.Status = postingStatus
+				trimmedLine = postingLine[2:]
+			}
+		}
+
+		if posting.Kind, trimmedLine, err = splitPostingKind(trimmedLine); err != nil {
+			return nil, err
+		}
+
+		// balance assertion follows the amount and any annotations
+		if iAssert := strings.IndexByte(trimmedLine, '='); iAssert > 0 && unicode.IsSpace(rune(trimmedLine[iAssert-1])) {
+			assertString := strings.TrimSpace(trimmedLine[iAssert+1:])
+			amt, commodity, aerr := parseCommodityAmount(lp.options.numberFormat, assertString)
+			if aerr != nil {
+				return nil, fmt.Errorf("unable to parse balance assertion(%s): %w", assertString, aerr)
+			}
+			posting.Assertion = &Assertion{Balance: amt, Commodity: commodity}
+			trimmedLine = strings.TrimRightFunc(trimmedLine[:iAssert], unicode.IsSpace)
+		}
+
+		// price and lot cost annotations follow the amount
+		var annotation string
+		if iAnnot := strings.IndexAny(trimmedLine, "@{"); iAnnot > 0 && unicode.IsSpace(rune(trimmedLine[iAnnot-1])) {
+			annotation = trimmedLine[iAnnot:]
+			trimmedLine = strings.TrimRightFunc(trimmedLine[:iAnnot], unicode.IsSpace)
+		}
 
 		if iSpace := strings.LastIndexFunc(trimmedLine, unicode.IsSpace); iSpace >= 0 {
-			if decbal, derr := decimal.NewFromString(trimmedLine[iSpace+1:]); derr == nil {
-				lp.postings[lp.cpIdx+accIndex].Name = strings.TrimSpace(trimmedLine[:iSpace])
-				lp.postings[lp.cpIdx+accIndex].Balance = decbal
+			lastField := trimmedLine[iSpace+1:]
+			if decbal, derr := lp.options.numberFormat.Parse(lastField); derr == nil {
+				posting.Name = strings.TrimSpace(trimmedLine[:iSpace])
+				posting.Balance = decbal
+			} else if decbal, commodity, cerr := parseAmount(lp.options.numberFormat, lastField); cerr == nil {
+				posting.Name = strings.TrimSpace(trimmedLine[:iSpace])
+				posting.Balance = decbal
+				posting.Commodity = commodity
+			} else if name, decbal, ok := splitQuantity(lp.options.numberFormat, trimmedLine[:iSpace]); ok && isCommodity(lastField) {
+				posting.Name = name
+				posting.Balance = decbal
+				posting.Commodity = lastField
 			} else if iParen := strings.Index(trimmedLine, "("); iParen >= 0 {
-				lp.postings[lp.cpIdx+accIndex].Name = strings.TrimSpace(trimmedLine[:iParen])
-				f, _ := compute.Evaluate(trimmedLine[iParen+1 : len(trimmedLine)-1])
-				lp.postings[lp.cpIdx+accIndex].Balance = decimal.NewFromFloat(f)
+				posting.Name = strings.TrimSpace(trimmedLine[:iParen])
+				expr := strings.TrimSpace(trimmedLine[iParen:])
+				if !strings.HasSuffix(expr, ")") {
+					return nil, fmt.Errorf("unable to parse amount expression(%s): missing )", expr)
+				}
+				decbal, eerr := lp.options.numberFormat.Evaluate(expr)
+				if eerr != nil {
+					return nil, fmt.Errorf("unable to parse amount expression(%s): %w", expr, eerr)
+				}
+				posting.Balance = decbal
+			} else if name, amount, found := cutAmount(trimmedLine); found {
+				// not part of the account name, as it follows a tab or two spaces
+				return nil, fmt.Errorf("unable to parse amount(%s) of %s", amount, name)
 			} else {
-				lp.postings[lp.cpIdx+accIndex].Name = strings.TrimSpace(trimmedLine)
+				posting.Name = strings.TrimSpace(trimmedLine)
 			}
 		} else {
-			lp.postings[lp.cpIdx+accIndex].Name = strings.TrimSpace(trimmedLine)
+			posting.Name = strings.TrimSpace(trimmedLine)
 		}
 
-		if lp.postings[lp.cpIdx+accIndex].Balance.IsZero() {
-			numEmpty++
-			emptyAccIndex = accIndex
+		if len(annotation) > 0 {
+			if cerr := parseCost(lp.options.numberFormat, posting, annotation); cerr != nil {
+				return nil, cerr
+			}
+		}
+
+		// a balance assertion without an amount is a balance assignment
+		assignment := posting.Assertion != nil && posting.Name == strings.TrimSpace(trimmedLine)
+
+		if lp.options.strict || posting.Assertion != nil {
+			ref := postingRef{filename: lp.scanner.Name(), line: lp.scanner.LineNumber(), column: textColumn(lp.scanner.line), account: posting.Name, commodity: posting.Commodity, posting: posting, assignment: assignment}
+			if assignment {
+				ref.commodity = posting.Assertion.Commodity
+			}
+			lp.postingRefs = append(lp.postingRefs, ref)
+			if lp.options.strict && posting.Cost != nil {
+				// the account is checked by the ref of the amount
+				ref.account = ""
+				for _, commodity := range []string{posting.Cost.Commodity, posting.Cost.LotCommodity} {
+					if len(commodity) > 0 && commodity != ref.commodity {
+						ref.commodity = commodity
+						lp.postingRefs = append(lp.postingRefs, ref)
+					}
+				}
+			}
+		}
+
+		switch {
+		case posting.Kind == VirtualPosting:
+			// unbalanced virtual postings do not need to balance
+		case assignment && posting.Kind == RealPosting:
+			numAssigned++
+		case assignment:
+			// balance assignments to virtual postings are not balanced
+		case posting.Kind == BalancedVirtualPosting:
+			err = lp.virtualBals.add(posting, accIndex)
+		default:
+			err = lp.realBals.add(posting, accIndex)
+		}
+		if err != nil {
+			return nil, err
 		}
-		transBal = transBal.Add(lp.postings[lp.cpIdx+accIndex].Balance)
 		accIndex++
 	}
 
@@ -282,24 +1225,41 @@ func (lp *parser) parseTransaction(dateString, payeeString, payeeComment string)
 		return
 	}
 
-	if !transBal.IsZero() {
-		switch numEmpty {
-		case 0:
-			return nil, errors.New("unable to balance transaction: no empty account to place extra balance")
-		case 1:
-			// If there is a single empty account, then it is obvious where to
-			// place the remaining balance.
-			lp.postings[lp.cpIdx+emptyAccIndex].Balance = transBal.Neg()
-		default:
+	// automated postings are balanced along with the postings of the
+	// transaction
+	if len(lp.autos) > 0 {
+		if accIndex, err = lp.applyAutomated(accIndex); err != nil {
+			return nil, err
+		}
+	}
+
+	if numAssigned > 0 {
+		// The amount of a balance assignment depends on the transactions
+		// before it by date, so balancing waits until all are parsed.
+		if lp.realBals.numEmpty > 1 {
 			return nil, errors.New("unable to balance transaction: more than one account empty")
 		}
+	} else if accIndex, err = lp.balance(&lp.realBals, accIndex); err != nil {
+		return nil, err
 	}
 
+	// balanced virtual postings balance separately from real postings
+	if accIndex, err = lp.balance(&lp.virtualBals, accIndex); err != nil {
+		return nil, err
+	}
+
+	lp.transactions[lp.ctIdx].Status = status
+	lp.transactions[lp.ctIdx].Code = code
 	lp.transactions[lp.ctIdx].Payee = payeeString
-	lp.transactions[lp.ctIdx].Date = transDate
 	lp.transactions[lp.ctIdx].PayeeComment = payeeComment
 	lp.transactions[lp.ctIdx].AccountChanges = lp.postings[lp.cpIdx : lp.cpIdx+accIndex]
 	lp.transactions[lp.ctIdx].Comments = lp.comments
+	tags := parseTags(payeeComment, nil)
+	for _, c := range lp.comments {
+		tags = parseTags(c, tags)
+	}
+	lp.transactions[lp.ctIdx].Tags = tags
+	lp.transactions[lp.ctIdx].Position = position
 
 	trans = &lp.transactions[lp.ctIdx]
 
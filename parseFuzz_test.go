@@ -18,14 +18,23 @@ func FuzzParseLedger(f *testing.F) {
 	f.Fuzz(func(t *testing.T, s string) {
 		b := bytes.NewBufferString(s)
 		trans, _ := ParseLedger(b)
-		overall := decimal.Zero
+		overall := make(map[string]decimal.Decimal)
 		for _, t := range trans {
 			for _, p := range t.AccountChanges {
-				overall = overall.Add(p.Balance)
+				if p.Kind != RealPosting {
+					continue
+				}
+				if p.Cost != nil {
+					overall[p.Cost.Commodity] = overall[p.Cost.Commodity].Add(p.Cost.Total)
+				} else {
+					overall[p.Commodity] = overall[p.Commodity].Add(p.Balance)
+				}
 			}
 		}
-		if !overall.IsZero() {
-			t.Error("Bad balance")
+		for _, bal := range overall {
+			if !bal.IsZero() {
+				t.Error("Bad balance")
+			}
 		}
 	})
 }
@@ -2,8 +2,12 @@ package ledger
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -513,6 +517,718 @@ account Assets
 		},
 		nil,
 	},
+	{
+		"commodities",
+		`1970/01/01 Payee
+	Assets:Broker  10 AAPL
+	Assets:Cash    -$1500
+	Assets:Bank    50€
+	Equity
+`,
+		[]*Transaction{
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					{
+						Name:      "Assets:Broker",
+						Balance:   decimal.NewFromFloat(10),
+						Commodity: "AAPL",
+					},
+					{
+						Name:      "Assets:Cash",
+						Balance:   decimal.NewFromFloat(-1500),
+						Commodity: "$",
+					},
+					{
+						Name:      "Assets:Bank",
+						Balance:   decimal.NewFromFloat(50),
+						Commodity: "€",
+					},
+					{
+						Name:      "Equity",
+						Balance:   decimal.NewFromFloat(-10),
+						Commodity: "AAPL",
+					},
+					{
+						Name:      "Equity",
+						Balance:   decimal.NewFromFloat(1500),
+						Commodity: "$",
+					},
+					{
+						Name:      "Equity",
+						Balance:   decimal.NewFromFloat(-50),
+						Commodity: "€",
+					},
+				},
+			},
+		},
+		nil,
+	},
+	{
+		"commodity balanced separately",
+		`1970/01/01 Payee
+	Assets:Broker  10 AAPL
+	Assets:Cash    $-1500
+`,
+		nil,
+		errors.New(":3: unable to parse transaction: unable to balance transaction: no empty account to place extra balance"),
+	},
+	{
+		"account name with single space before number",
+		`1970/01/01 Payee
+	Expenses:Car 2 Insurance
+	Assets  -20
+`,
+		[]*Transaction{
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					{
+						Name:    "Expenses:Car 2 Insurance",
+						Balance: decimal.NewFromFloat(20),
+					},
+					{
+						Name:    "Assets",
+						Balance: decimal.NewFromFloat(-20),
+					},
+				},
+			},
+		},
+		nil,
+	},
+	{
+		"price annotations",
+		`1970/01/01 Payee
+	Assets:Broker  10 AAPL @ $150
+	Assets:Broker  -5 AAPL {$140} @@ $800
+	Assets:Cash
+`,
+		[]*Transaction{
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					{
+						Name:      "Assets:Broker",
+						Balance:   decimal.NewFromFloat(10),
+						Commodity: "AAPL",
+						Cost: &Cost{
+							Price:     decimal.NewFromFloat(150),
+							Total:     decimal.NewFromFloat(1500),
+							Commodity: "$",
+						},
+					},
+					{
+						Name:      "Assets:Broker",
+						Balance:   decimal.NewFromFloat(-5),
+						Commodity: "AAPL",
+						Cost: &Cost{
+							Price:        decimal.NewFromFloat(160),
+							Total:        decimal.NewFromFloat(-800),
+							Commodity:    "$",
+							LotPrice:     decimal.NewFromFloat(140),
+							LotCommodity: "$",
+						},
+					},
+					{
+						Name:      "Assets:Cash",
+						Balance:   decimal.NewFromFloat(-700),
+						Commodity: "$",
+					},
+				},
+			},
+		},
+		nil,
+	},
+	{
+		"price annotation without commodity",
+		`1970/01/01 Payee
+	Assets:Broker  10 @ $150
+	Assets:Cash
+`,
+		nil,
+		errors.New(":2: unable to parse transaction: price annotation requires an amount with a commodity"),
+	},
+	{
+		"bad price annotation",
+		`1970/01/01 Payee
+	Assets:Broker  10 AAPL {$150
+	Assets:Cash
+`,
+		nil,
+		errors.New(":2: unable to parse transaction: unable to parse lot cost({$150): missing }"),
+	},
+	{
+		"balance assertion",
+		`1970/01/02 Payee
+	Assets:Checking   -50 = 150
+	Expenses
+
+1970/01/01 Payee
+	Assets:Checking   200
+	Income
+`,
+		[]*Transaction{
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC().AddDate(0, 0, 1),
+				AccountChanges: []Account{
+					{
+						Name:      "Assets:Checking",
+						Balance:   decimal.NewFromFloat(-50),
+						Assertion: &Assertion{Balance: decimal.NewFromFloat(150)},
+					},
+					{
+						Name:    "Expenses",
+						Balance: decimal.NewFromFloat(50),
+					},
+				},
+			},
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					{
+						Name:    "Assets:Checking",
+						Balance: decimal.NewFromFloat(200),
+					},
+					{
+						Name:    "Income",
+						Balance: decimal.NewFromFloat(-200),
+					},
+				},
+			},
+		},
+		nil,
+	},
+	{
+		"failed balance assertion",
+		`1970/01/01 Payee
+	Assets:Checking   200 AAPL
+	Income
+
+1970/01/02 Payee
+	Assets:Checking   $-50 = $150
+	Assets:Checking   -50 AAPL = 140 AAPL
+	Expenses
+`,
+		[]*Transaction{
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					{
+						Name:      "Assets:Checking",
+						Balance:   decimal.NewFromFloat(200),
+						Commodity: "AAPL",
+					},
+					{
+						Name:      "Income",
+						Balance:   decimal.NewFromFloat(-200),
+						Commodity: "AAPL",
+					},
+				},
+			},
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC().AddDate(0, 0, 1),
+				AccountChanges: []Account{
+					{
+						Name:      "Assets:Checking",
+						Balance:   decimal.NewFromFloat(-50),
+						Commodity: "$",
+						Assertion: &Assertion{Balance: decimal.NewFromFloat(150), Commodity: "$"},
+					},
+					{
+						Name:      "Assets:Checking",
+						Balance:   decimal.NewFromFloat(-50),
+						Commodity: "AAPL",
+						Assertion: &Assertion{Balance: decimal.NewFromFloat(140), Commodity: "AAPL"},
+					},
+					{
+						Name:      "Expenses",
+						Balance:   decimal.NewFromFloat(50),
+						Commodity: "$",
+					},
+					{
+						Name:      "Expenses",
+						Balance:   decimal.NewFromFloat(50),
+						Commodity: "AAPL",
+					},
+				},
+			},
+		},
+		errors.New(":6: balance assertion failed for Assets:Checking: expected $150.00, got $-50.00\n:7: balance assertion failed for Assets:Checking: expected 140.00 AAPL, got 150.00 AAPL"),
+	},
+	{
+		"balance assignment",
+		`1970/01/01 Payee
+	Assets:Checking   200
+	Income
+
+1970/01/02 Payee
+	Assets:Checking   = 150
+	Expenses
+`,
+		[]*Transaction{
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					{
+						Name:    "Assets:Checking",
+						Balance: decimal.NewFromFloat(200),
+					},
+					{
+						Name:    "Income",
+						Balance: decimal.NewFromFloat(-200),
+					},
+				},
+			},
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC().AddDate(0, 0, 1),
+				AccountChanges: []Account{
+					{
+						Name:      "Assets:Checking",
+						Balance:   decimal.NewFromFloat(-50),
+						Assertion: &Assertion{Balance: decimal.NewFromFloat(150)},
+					},
+					{
+						Name:    "Expenses",
+						Balance: decimal.NewFromFloat(50),
+					},
+				},
+			},
+		},
+		nil,
+	},
+	{
+		"balance assignment without empty account",
+		`1970/01/01 Payee
+	Assets:Checking   = 150
+	Expenses          50
+`,
+		[]*Transaction{
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					{
+						Name:      "Assets:Checking",
+						Balance:   decimal.NewFromFloat(150),
+						Assertion: &Assertion{Balance: decimal.NewFromFloat(150)},
+					},
+					{
+						Name:    "Expenses",
+						Balance: decimal.NewFromFloat(50),
+					},
+				},
+			},
+		},
+		errors.New(":2: unable to balance transaction: no empty account to place extra balance"),
+	},
+	{
+		"status and code",
+		`2024/01/02 * (1042) Landlord
+	Expenses:Rent     1000
+	! Assets:Checking
+
+2024/01/03 ! Store
+	Expenses:Food     50
+	Assets:Checking
+`,
+		[]*Transaction{
+			{
+				Payee:  "Landlord",
+				Date:   time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+				Status: Cleared,
+				Code:   "1042",
+				AccountChanges: []Account{
+					{
+						Name:    "Expenses:Rent",
+						Balance: decimal.NewFromFloat(1000),
+					},
+					{
+						Name:    "Assets:Checking",
+						Status:  Pending,
+						Balance: decimal.NewFromFloat(-1000),
+					},
+				},
+			},
+			{
+				Payee:  "Store",
+				Date:   time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC),
+				Status: Pending,
+				AccountChanges: []Account{
+					{
+						Name:    "Expenses:Food",
+						Balance: decimal.NewFromFloat(50),
+					},
+					{
+						Name:    "Assets:Checking",
+						Balance: decimal.NewFromFloat(-50),
+					},
+				},
+			},
+		},
+		nil,
+	},
+	{
+		"metadata tags",
+		`; :reimbursable:
+2024/01/02 Store  ; Project: kitchen
+	Expenses:Tools    100  ; :tools:hardware: see receipt
+	Expenses:Food      20  ; Project: lunch
+	Assets:Checking        ; 10:30 pickup
+`,
+		[]*Transaction{
+			{
+				Payee:        "Store",
+				Date:         time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC),
+				PayeeComment: "; Project: kitchen",
+				AccountChanges: []Account{
+					{
+						Name:    "Expenses:Tools",
+						Balance: decimal.NewFromFloat(100),
+						Comment: "; :tools:hardware: see receipt",
+						Tags:    map[string]string{"tools": "", "hardware": ""},
+					},
+					{
+						Name:    "Expenses:Food",
+						Balance: decimal.NewFromFloat(20),
+						Comment: "; Project: lunch",
+						Tags:    map[string]string{"Project": "lunch"},
+					},
+					{
+						Name:    "Assets:Checking",
+						Balance: decimal.NewFromFloat(-120),
+						Comment: "; 10:30 pickup",
+					},
+				},
+				Comments: []string{"; :reimbursable:"},
+				Tags:     map[string]string{"Project": "kitchen", "reimbursable": ""},
+			},
+		},
+		nil,
+	},
+	{
+		"bad periodic transaction",
+		`~ Fortnightly
+	Expenses:Rent     1000
+	Assets:Checking
+`,
+		nil,
+		errors.New(":3: unable to parse periodic transaction: unknown period(Fortnightly)"),
+	},
+	{
+		"automated transaction",
+		`= /^Income:Salary$/
+	Assets:Savings:Tax      *-0.10  ; set aside
+	Assets:Checking         *0.10
+
+1970/01/01 Payee
+	Income:Salary          -1000
+	Assets:Checking
+`,
+		[]*Transaction{
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					{
+						Name:    "Income:Salary",
+						Balance: decimal.NewFromFloat(-1000),
+					},
+					{
+						Name:    "Assets:Checking",
+						Balance: decimal.NewFromFloat(1000),
+					},
+					{
+						Name:    "Assets:Savings:Tax",
+						Balance: decimal.NewFromFloat(100),
+						Comment: "; set aside",
+					},
+					{
+						Name:    "Assets:Checking",
+						Balance: decimal.NewFromFloat(-100),
+					},
+				},
+			},
+		},
+		nil,
+	},
+	{
+		"automated transaction balanced with the transaction",
+		`= /^Income:Salary$/
+	Assets:Savings:Tax      *-0.10
+
+1970/01/01 Payee
+	Income:Salary          -1000
+	Assets:Checking
+`,
+		[]*Transaction{
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					{
+						Name:    "Income:Salary",
+						Balance: decimal.NewFromFloat(-1000),
+					},
+					{
+						Name:    "Assets:Checking",
+						Balance: decimal.NewFromFloat(900),
+					},
+					{
+						Name:    "Assets:Savings:Tax",
+						Balance: decimal.NewFromFloat(100),
+					},
+				},
+			},
+		},
+		nil,
+	},
+	{
+		"automated transaction that does not balance",
+		`= /^Income:Salary$/
+	Assets:Savings:Tax      *-0.10
+
+1970/01/01 Payee
+	Income:Salary          -1000
+	Assets:Checking         1000
+`,
+		nil,
+		errors.New(":6: unable to parse transaction: unable to balance transaction: no empty account to place extra balance"),
+	},
+	{
+		"automated multiplier of a posting without an amount",
+		`= /^Expenses:Food$/
+	(Budget:Food)           *-1
+
+1970/01/01 Payee
+	Expenses:Food
+	Assets:Checking        -50
+`,
+		nil,
+		errors.New(":6: unable to parse transaction: unable to apply automated transaction(/^Expenses:Food$/): Expenses:Food has no amount to multiply"),
+	},
+	{
+		"automated virtual postings",
+		`= /^Expenses:Food$/
+	(Budget:Food)           *-1
+	[Savings]               $5
+	[Assets:Checking]       $-5
+
+1970/01/01 Payee
+	Expenses:Food           50
+	Assets:Checking
+`,
+		[]*Transaction{
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					{
+						Name:    "Expenses:Food",
+						Balance: decimal.NewFromFloat(50),
+					},
+					{
+						Name:    "Assets:Checking",
+						Balance: decimal.NewFromFloat(-50),
+					},
+					{
+						Name:    "Budget:Food",
+						Kind:    VirtualPosting,
+						Balance: decimal.NewFromFloat(-50),
+					},
+					{
+						Name:      "Savings",
+						Kind:      BalancedVirtualPosting,
+						Balance:   decimal.NewFromFloat(5),
+						Commodity: "$",
+					},
+					{
+						Name:      "Assets:Checking",
+						Kind:      BalancedVirtualPosting,
+						Balance:   decimal.NewFromFloat(-5),
+						Commodity: "$",
+					},
+				},
+			},
+		},
+		nil,
+	},
+	{
+		"bad automated transaction",
+		`= /^Income:Salary$/
+	Assets:Savings:Tax      *ten
+	Assets:Checking         *0.10
+`,
+		nil,
+		errors.New(":2: unable to parse automated transaction: unable to parse automated posting amount(*ten): invalid syntax"),
+	},
+	{
+		"virtual postings",
+		`1970/01/01 Payee
+	Expenses:Food        20
+	Assets:Checking
+	(Budget:Food)       -20
+	[Savings:Goal]       50
+	[Assets:Checking]
+`,
+		[]*Transaction{
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					{
+						Name:    "Expenses:Food",
+						Balance: decimal.NewFromFloat(20),
+					},
+					{
+						Name:    "Assets:Checking",
+						Balance: decimal.NewFromFloat(-20),
+					},
+					{
+						Name:    "Budget:Food",
+						Kind:    VirtualPosting,
+						Balance: decimal.NewFromFloat(-20),
+					},
+					{
+						Name:    "Savings:Goal",
+						Kind:    BalancedVirtualPosting,
+						Balance: decimal.NewFromFloat(50),
+					},
+					{
+						Name:    "Assets:Checking",
+						Kind:    BalancedVirtualPosting,
+						Balance: decimal.NewFromFloat(-50),
+					},
+				},
+			},
+		},
+		nil,
+	},
+	{
+		"unbalanced virtual postings",
+		`1970/01/01 Payee
+	Expenses:Food        20
+	Assets:Checking
+	[Savings:Goal]       50
+	[Assets:Checking]   -40
+`,
+		nil,
+		errors.New(":5: unable to parse transaction: unable to balance transaction: no empty account to place extra balance"),
+	},
+	{
+		"bad virtual posting",
+		`1970/01/01 Payee
+	Expenses:Food        20
+	(Budget:Food  -20
+	Assets:Checking
+`,
+		nil,
+		errors.New(":3: unable to parse transaction: unable to parse virtual account((Budget:Food  -20): missing )"),
+	},
+	{
+		"exact expression",
+		`1970/01/01 Payee
+	Expense/test  (4.35 * 100 - -(1 / 8))
+	Assets
+`,
+		[]*Transaction{
+			{
+				Payee: "Payee",
+				Date:  time.Unix(0, 0).UTC(),
+				AccountChanges: []Account{
+					{
+						Name:    "Expense/test",
+						Balance: decimal.NewFromFloat(435.125),
+					},
+					{
+						Name:    "Assets",
+						Balance: decimal.NewFromFloat(-435.125),
+					},
+				},
+			},
+		},
+		nil,
+	},
+	{
+		"bad expression",
+		`1970/01/01 Payee
+	Expense/test  (123 * )
+	Assets
+`,
+		nil,
+		errors.New(":2: unable to parse transaction: unable to parse amount expression((123 * )): unexpected ')' at position 8"),
+	},
+	{
+		"unclosed expression",
+		`1970/01/01 Payee
+	Expense/test  (
+	Assets
+`,
+		nil,
+		errors.New(":2: unable to parse transaction: unable to parse amount expression((): missing )"),
+	},
+	{
+		"effective dates",
+		`2024/01/05=2024/01/08 Card Purchase
+	Expenses:Food        25
+	Liabilities:Card          ; [=2024/01/09]
+`,
+		[]*Transaction{
+			{
+				Payee:         "Card Purchase",
+				Date:          time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC),
+				EffectiveDate: time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+				AccountChanges: []Account{
+					{
+						Name:    "Expenses:Food",
+						Balance: decimal.NewFromFloat(25),
+					},
+					{
+						Name:          "Liabilities:Card",
+						Balance:       decimal.NewFromFloat(-25),
+						Comment:       "; [=2024/01/09]",
+						EffectiveDate: time.Date(2024, 1, 9, 0, 0, 0, 0, time.UTC),
+					},
+				},
+			},
+		},
+		nil,
+	},
+	{
+		"bad effective date",
+		`2024/01/05=2024/01/38 Card Purchase
+	Expenses:Food        25
+	Liabilities:Card
+`,
+		nil,
+		errors.New(":1: unable to parse transaction: unable to parse date(2024/01/38): parsing time \"2024/01/38\": extra text: \"2024/01/38\""),
+	},
+	{
+		"bad posting effective date",
+		`2024/01/05 Card Purchase
+	Expenses:Food        25
+	Liabilities:Card          ; [=2024/01/09
+`,
+		nil,
+		errors.New(":3: unable to parse transaction: unable to parse effective date(; [=2024/01/09): missing ]"),
+	},
+	{
+		"bad balance assertion",
+		`1970/01/01 Payee
+	Assets:Checking   -50 = abc
+	Expenses
+`,
+		nil,
+		errors.New(":2: unable to parse transaction: unable to parse balance assertion(abc): no amount"),
+	},
 }
 
 func TestParseLedger(t *testing.T) {
@@ -522,6 +1238,7 @@ func TestParseLedger(t *testing.T) {
 		if (err != nil && tc.err == nil) || (err != nil && tc.err != nil && err.Error() != tc.err.Error()) {
 			t.Errorf("Error: expected `%s`, got `%s`", tc.err, err)
 		}
+		clearPositions(transactions)
 		exp, _ := json.Marshal(tc.transactions)
 		got, _ := json.Marshal(transactions)
 		if string(exp) != string(got) {
@@ -530,6 +1247,77 @@ func TestParseLedger(t *testing.T) {
 	}
 }
 
+// clearPositions removes the positions of parsed transactions and postings,
+// which are checked by TestParsePositions.
+func clearPositions(transactions []*Transaction) {
+	for _, trans := range transactions {
+		trans.Position = Position{}
+		for i := range trans.AccountChanges {
+			trans.AccountChanges[i].Line = 0
+		}
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	journal, err := ParseJournalFile("testdata/positions.dat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.SortFunc(journal.Transactions, func(a, b *Transaction) int {
+		return a.Date.Compare(b.Date)
+	})
+
+	var got []string
+	for _, trans := range journal.Transactions {
+		got = append(got, trans.Payee+" "+trans.Position.String())
+		for _, posting := range trans.AccountChanges {
+			got = append(got, fmt.Sprintf("%s %d", posting.Name, posting.Line))
+		}
+	}
+	exp := []string{
+		"Payee testdata/positions.dat:6-10",
+		"Expenses 8",
+		"Assets 10",
+		"Savings 0",
+		"Included testdata/positions-include.dat:1-3",
+		"Food 2",
+		"Assets 3",
+	}
+	if !slices.Equal(exp, got) {
+		t.Errorf("expected \n`%s`, \ngot \n`%s`", strings.Join(exp, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestParseManyPostings(t *testing.T) {
+	var buf strings.Builder
+	buf.WriteString("2024/01/01 Large\n\tExpenses:0    1 = 1\n")
+	for i := 1; i < 3500; i++ {
+		fmt.Fprintf(&buf, "\tExpenses:%d    1\n", i)
+	}
+	buf.WriteString("\tAssets\n\n")
+	for i := range 100 {
+		fmt.Fprintf(&buf, "2024/01/02 Medium %d\n", i)
+		for j := range 50 {
+			fmt.Fprintf(&buf, "\tExpenses:%d    1\n", j)
+		}
+		buf.WriteString("\tAssets\n\n")
+	}
+
+	journal, err := ParseJournal(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journal.Transactions) != 101 {
+		t.Fatalf("expected 101 transactions, got %d", len(journal.Transactions))
+	}
+	for _, trans := range journal.Transactions {
+		last := trans.AccountChanges[len(trans.AccountChanges)-1]
+		if exp := decimal.NewFromInt(int64(1 - len(trans.AccountChanges))); last.Name != "Assets" || last.Balance.Cmp(exp) != 0 {
+			t.Errorf("%s: expected Assets %s, got %s %s", trans.Payee, exp.StringRound(), last.Name, last.Balance.StringRound())
+		}
+	}
+}
+
 func TestParseLedgerAsync(t *testing.T) {
 	buf := bytes.NewBufferString(`; test
 account bam:bam
@@ -576,6 +1364,363 @@ account endofledger`)
 	}
 }
 
+func TestParseTransactions(t *testing.T) {
+	data := `2024/01/01 First
+	Assets       50
+	Expenses
+
+2024/01/02 Error
+	Assets   30
+	Expenses  -20
+
+2024/01/03 Third
+	Assets       20
+	Expenses
+
+2024/01/02 Fourth
+	Assets       10
+	Expenses
+`
+
+	var payees []string
+	var errs []error
+	for trans, err := range ParseTransactions(context.Background(), strings.NewReader(data)) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		payees = append(payees, trans.Payee)
+	}
+	if exp := []string{"First", "Third", "Fourth"}; !slices.Equal(payees, exp) {
+		t.Errorf("expected %v, got %v", exp, payees)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "unable to balance transaction") {
+		t.Errorf("expected one balance error, got %v", errs)
+	}
+
+	payees = payees[:0]
+	for trans, err := range ParseTransactions(context.Background(), strings.NewReader(data)) {
+		if err != nil {
+			break
+		}
+		payees = append(payees, trans.Payee)
+	}
+	if exp := []string{"First"}; !slices.Equal(payees, exp) {
+		t.Errorf("expected %v after stopping, got %v", exp, payees)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	payees = payees[:0]
+	errs = errs[:0]
+	for trans, err := range ParseTransactions(ctx, strings.NewReader(data)) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		payees = append(payees, trans.Payee)
+		cancel()
+	}
+	if exp := []string{"First"}; !slices.Equal(payees, exp) {
+		t.Errorf("expected %v after cancel, got %v", exp, payees)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+		t.Errorf("expected context canceled, got %v", errs)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	buf := bytes.NewBufferString(`2024/01/01 Good
+	Assets       10
+	Expenses
+
+2024/02/30 Bad date
+	Assets       30
+	Expenses
+
+2024/01/02 Bad account
+	(Assets       30
+	Expenses
+
+P 2024/01/01
+
+2024/01/03 Good again
+	Assets       10
+	Expenses
+`)
+	trans, err := ParseLedger(buf)
+	if len(trans) != 2 || trans[0].Payee != "Good" || trans[1].Payee != "Good again" {
+		t.Errorf("expected transactions around the errors to parse, got %v", trans)
+	}
+
+	type errorPos struct {
+		line, column int
+		kind         ErrorKind
+	}
+	var got []errorPos
+	for _, perr := range ParseErrors(err) {
+		got = append(got, errorPos{perr.Line, perr.Column, perr.Kind})
+	}
+	exp := []errorPos{
+		{5, 1, TransactionError},
+		{10, 2, TransactionError},
+		{13, 1, PriceError},
+	}
+	if !slices.Equal(got, exp) {
+		t.Errorf("expected errors %v, got %v\n%v", exp, got, err)
+	}
+
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.Line != 5 {
+		t.Errorf("expected first error to be a ParseError, got %v", err)
+	}
+}
+
+func TestParseErrorsCheckBalances(t *testing.T) {
+	data := `account Assets:Checking
+account Income
+account Expenses
+
+1970/01/01 Payee
+	Assets:Checking   200
+	Income
+
+1970/01/32 Bad date
+	Assets:Checking   30
+	Expenses
+
+1970/01/02 Payee
+	Assets:Checking   = 150
+	Expenses
+
+1970/01/03 Payee
+	Assets:Checking   10 = 100
+	Expenses
+`
+	for _, opts := range [][]ParseOption{nil, {WithStrict()}} {
+		trans, err := ParseLedger(strings.NewReader(data), opts...)
+		if len(trans) != 3 {
+			t.Fatalf("expected 3 transactions, got %d", len(trans))
+		}
+		if bal := trans[1].AccountChanges[0].Balance; bal.Cmp(decimal.NewFromFloat(-50)) != 0 {
+			t.Errorf("expected balance assignment of -50, got %s", bal.StringFixedBank())
+		}
+		var kinds []ErrorKind
+		for _, perr := range ParseErrors(err) {
+			kinds = append(kinds, perr.Kind)
+		}
+		if exp := []ErrorKind{TransactionError, BalanceAssertionError}; !slices.Equal(kinds, exp) {
+			t.Errorf("expected errors %v, got %v\n%v", exp, kinds, err)
+		}
+	}
+}
+
+func TestParseJournalPrices(t *testing.T) {
+	buf := bytes.NewBufferString(`P 2024/01/05 AAPL $185.20
+P 2024/01/01 AAPL $180
+P 2024/01/05 EUR 1.10 USD
+
+2024/01/02 Payee
+	Assets:Broker   10 AAPL
+	Equity
+`)
+
+	journal, err := ParseJournal(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(journal.Transactions) != 1 {
+		t.Errorf("expected 1 transaction, got %d", len(journal.Transactions))
+	}
+
+	for _, tc := range []struct {
+		commodity string
+		date      time.Time
+		found     bool
+		amount    decimal.Decimal
+		priceComm string
+	}{
+		{"AAPL", time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC), false, decimal.Zero, ""},
+		{"AAPL", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), true, decimal.NewFromInt(180), "$"},
+		{"AAPL", time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC), true, decimal.NewFromInt(180), "$"},
+		{"AAPL", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), true, decimal.NewFromFloat(185.2), "$"},
+		{"EUR", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), true, decimal.NewFromFloat(1.1), "USD"},
+		{"MSFT", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), false, decimal.Zero, ""},
+	} {
+		p, found := journal.Prices.PriceAt(tc.commodity, tc.date)
+		if found != tc.found || p.Amount.Cmp(tc.amount) != 0 || p.PriceCommodity != tc.priceComm {
+			t.Errorf("PriceAt(%s, %s): got %v %v", tc.commodity, tc.date.Format(time.DateOnly), p, found)
+		}
+	}
+
+	_, err = ParseJournal(bytes.NewBufferString("P 2024/01/05 AAPL"))
+	if err == nil || err.Error() != ":1: unable to parse price: invalid amount(): no amount" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseJournalAccounts(t *testing.T) {
+	buf := bytes.NewBufferString(`account Expenses:Groceries  ; food
+	note Weekly shopping
+	alias groc
+	assert abs(amount) < 1000
+	check commodity == "$"
+account Assets:Checking
+	default
+
+1970/01/01 Payee
+	groc     50
+	Assets:Checking
+`)
+
+	journal, err := ParseJournal(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp, _ := json.Marshal([]*AccountDeclaration{
+		{
+			Name:     "Expenses:Groceries",
+			Note:     "Weekly shopping",
+			Aliases:  []string{"groc"},
+			Asserts:  []string{"abs(amount) < 1000"},
+			Checks:   []string{`commodity == "$"`},
+			Comments: []string{"; food"},
+		},
+		{
+			Name:    "Assets:Checking",
+			Default: true,
+		},
+	})
+	got, _ := json.Marshal(journal.Accounts)
+	if string(exp) != string(got) {
+		t.Errorf("Error: expected \n`%s`, \ngot \n`%s`", exp, got)
+	}
+
+	if len(journal.Transactions) != 1 || journal.Transactions[0].AccountChanges[0].Name != "Expenses:Groceries" {
+		t.Error("alias not applied to posting")
+	}
+}
+
+func TestParseStrict(t *testing.T) {
+	data := `account Assets:Checking
+account Expenses:Food
+	alias food
+commodity USD
+	alias $
+	format 1,000.00 USD
+
+1970/01/01 Payee
+	food            $50
+	Assets:Checking
+
+1970/01/02 Payee
+	Expenses:Food          10 AAPL @ $5
+	Expenses:Unknown       20
+	Assets:Checking
+
+1970/01/03 Payee
+	Expenses:Other         5 USD @ $5
+	Expenses:Other         5 USD {$4} @ EUR5
+	Assets:Checking
+`
+
+	_, err := ParseLedger(bytes.NewBufferString(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	journal, err := ParseJournal(bytes.NewBufferString(data), WithStrict())
+	exp := `:13: undeclared commodity(AAPL)
+:14: undeclared account(Expenses:Unknown)
+:18: undeclared account(Expenses:Other)
+:19: undeclared account(Expenses:Other)
+:19: undeclared commodity(EUR)`
+	if err == nil || err.Error() != exp {
+		t.Errorf("Error: expected \n`%s`, \ngot \n`%v`", exp, err)
+	}
+
+	if len(journal.Commodities) != 1 || journal.Commodities[0].Format != "1,000.00 USD" {
+		t.Error("commodity declaration not parsed")
+	}
+	if posting := journal.Transactions[0].AccountChanges[0]; posting.Name != "Expenses:Food" || posting.Commodity != "USD" {
+		t.Error("aliases not applied to posting")
+	}
+}
+
+func TestParseNumberFormat(t *testing.T) {
+	european := decimal.NumberFormat{Decimal: ',', Group: '.'}
+	journal, err := ParseJournal(bytes.NewBufferString(`commodity EUR
+	format 1.000,000 EUR
+
+1970/01/01 Payee
+	Expenses:Rent          1.000,50
+	Expenses:Food          (2,5 * 2)
+	Assets:Checking
+`), WithNumberFormat(european))
+	if err != nil {
+		t.Fatal(err)
+	}
+	postings := journal.Transactions[0].AccountChanges
+	for i, exp := range []string{"1000.50", "5.00", "-1005.50"} {
+		if got := postings[i].Balance.StringFixed(2); got != exp {
+			t.Errorf("Error(%s): expected `%s`, got `%s`", postings[i].Name, exp, got)
+		}
+	}
+	if places, found := journal.Commodities[0].Places(european); !found || places != 3 {
+		t.Errorf("Error: expected 3 places, got %d", places)
+	}
+
+	_, err = ParseLedger(bytes.NewBufferString(`1970/01/01 Payee
+	Expenses:Rent          $1,000.00
+	Assets:Checking
+`))
+	if err == nil || err.Error() != ":2: unable to parse transaction: unable to parse amount($1,000.00) of Expenses:Rent" {
+		t.Errorf("Error: unexpected error `%v`", err)
+	}
+
+	// an amount in parentheses is an expression, not a negative amount
+	trans, err := ParseLedger(bytes.NewBufferString(`1970/01/01 Payee
+	Expenses:Rent          (1,000.00)
+	Assets:Checking
+`), WithNumberFormat(decimal.NumberFormat{Group: ',', Parens: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := trans[0].AccountChanges[0].Balance.StringFixed(2); got != "1000.00" {
+		t.Errorf("Error: expected `1000.00`, got `%s`", got)
+	}
+}
+
+func TestPostingHasTag(t *testing.T) {
+	trans := &Transaction{
+		Tags: map[string]string{"Project": "kitchen", "reimbursable": ""},
+		AccountChanges: []Account{
+			{Name: "Expenses:Tools"},
+			{Name: "Expenses:Food", Tags: map[string]string{"Project": "lunch"}},
+		},
+	}
+
+	for _, tc := range []struct {
+		posting int
+		tag     string
+		has     bool
+	}{
+		{0, "Project", true},
+		{0, "Project=kitchen", true},
+		{0, "reimbursable", true},
+		{0, "reimbursable=", true},
+		{0, "Project=lunch", false},
+		{0, "missing", false},
+		{1, "Project=lunch", true},
+		{1, "Project=kitchen", false},
+	} {
+		if has := trans.PostingHasTag(&trans.AccountChanges[tc.posting], tc.tag); has != tc.has {
+			t.Errorf("Error: posting %d tag %s expected %t, got %t", tc.posting, tc.tag, tc.has, has)
+		}
+	}
+}
+
 func BenchmarkParseLedger(b *testing.B) {
 	for b.Loop() {
 		_, _ = ParseLedgerFile("testdata/ledgerBench.dat")
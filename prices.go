@@ -0,0 +1,184 @@
+package ledger
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/howeyc/ledger/decimal"
+)
+
+// PriceHistory holds the prices of commodities over time, as given by P
+// directives. The zero value is an empty history ready to use.
+type PriceHistory struct {
+	prices map[string][]Price
+}
+
+// Add adds a price to the history. Prices of each commodity are kept sorted
+// by date, then by price commodity. A later price on the same date in the
+// same price commodity replaces an earlier one.
+func (ph *PriceHistory) Add(p Price) {
+	if ph.prices == nil {
+		ph.prices = make(map[string][]Price)
+	}
+	cprices := ph.prices[p.Commodity]
+	idx, found := slices.BinarySearchFunc(cprices, p, func(e, t Price) int {
+		return cmp.Or(
+			e.Date.Compare(t.Date),
+			strings.Compare(e.PriceCommodity, t.PriceCommodity),
+		)
+	})
+	if found {
+		cprices[idx] = p
+	} else {
+		cprices = slices.Insert(cprices, idx, p)
+	}
+	ph.prices[p.Commodity] = cprices
+}
+
+// Merge adds all prices of other to the history.
+func (ph *PriceHistory) Merge(other PriceHistory) {
+	for _, cprices := range other.prices {
+		for _, p := range cprices {
+			ph.Add(p)
+		}
+	}
+}
+
+// PriceAt returns the latest price of commodity on or before date. Of
+// several prices on that date, the one whose price commodity sorts last is
+// returned. Returns false if there is no such price.
+func (ph PriceHistory) PriceAt(commodity string, date time.Time) (Price, bool) {
+	cprices := ph.prices[commodity]
+	idx := ph.pricesUntil(commodity, date)
+	if idx == 0 {
+		return Price{}, false
+	}
+	return cprices[idx-1], true
+}
+
+// priceIn returns the latest price of commodity in target on or before date.
+// Returns false if there is no such price.
+func (ph PriceHistory) priceIn(commodity, target string, date time.Time) (Price, bool) {
+	cprices := ph.prices[commodity]
+	for idx := ph.pricesUntil(commodity, date) - 1; idx >= 0; idx-- {
+		if cprices[idx].PriceCommodity == target {
+			return cprices[idx], true
+		}
+	}
+	return Price{}, false
+}
+
+// pricesUntil returns the number of prices of commodity on or before date.
+func (ph PriceHistory) pricesUntil(commodity string, date time.Time) int {
+	idx, _ := slices.BinarySearchFunc(ph.prices[commodity], date, func(e Price, t time.Time) int {
+		if e.Date.After(t) {
+			return 1
+		}
+		return -1
+	})
+	return idx
+}
+
+// Prices returns the price history of commodity sorted by date.
+func (ph PriceHistory) Prices(commodity string) []Price {
+	return slices.Clone(ph.prices[commodity])
+}
+
+// Commodities returns the commodities that have prices, sorted by name.
+func (ph PriceHistory) Commodities() []string {
+	commodities := make([]string, 0, len(ph.prices))
+	for commodity := range ph.prices {
+		commodities = append(commodities, commodity)
+	}
+	slices.Sort(commodities)
+	return commodities
+}
+
+// exchange converts amount of commodity to target using a price of
+// commodity in target, or the inverse of a price of target in commodity.
+func (ph PriceHistory) exchange(amount decimal.Decimal, commodity, target string, date time.Time) (decimal.Decimal, bool) {
+	if p, found := ph.priceIn(commodity, target, date); found {
+		if value, err := amount.CheckedMul(p.Amount); err == nil {
+			return value, true
+		}
+	}
+	if p, found := ph.priceIn(target, commodity, date); found && !p.Amount.IsZero() {
+		if value, err := amount.CheckedDiv(p.Amount); err == nil {
+			return value, true
+		}
+	}
+	return amount, false
+}
+
+// Value returns amount of commodity converted to the target commodity using
+// the latest prices on or before date. When there is no price between the
+// two commodities, the amount is converted through the commodity it is
+// priced in. With an empty target, amount is converted to the commodity it
+// is priced in.
+//
+// Returns the amount unchanged and false if no conversion is possible, or the
+// converted amount does not fit in a decimal.Decimal.
+func (ph PriceHistory) Value(amount decimal.Decimal, commodity, target string, date time.Time) (value decimal.Decimal, valueCommodity string, ok bool) {
+	if commodity == target {
+		return amount, commodity, true
+	}
+
+	p, found := ph.PriceAt(commodity, date)
+	if target == "" {
+		if !found {
+			return amount, commodity, false
+		}
+		if value, err := amount.CheckedMul(p.Amount); err == nil {
+			return value, p.PriceCommodity, true
+		}
+		return amount, commodity, false
+	}
+
+	if value, ok = ph.exchange(amount, commodity, target, date); ok {
+		return value, target, true
+	}
+	if found {
+		if priced, err := amount.CheckedMul(p.Amount); err == nil {
+			if value, ok = ph.exchange(priced, p.PriceCommodity, target, date); ok {
+				return value, target, true
+			}
+		}
+	}
+	return amount, commodity, false
+}
+
+// MarketBalances returns balances with the balance of each commodity
+// converted to target (see Value) using prices on or before date. Balances
+// that can not be converted are kept in their own commodity.
+//
+// Accounts are sorted by name, then by commodity.
+func (ph PriceHistory) MarketBalances(balances []*Account, target string, date time.Time) ([]*Account, error) {
+	results := make([]*Account, 0, len(balances))
+	values := make(map[balanceKey]*Account)
+	for _, bal := range balances {
+		value, valueCommodity, _ := ph.Value(bal.Balance, bal.Commodity, target, date)
+		key := balanceKey{name: bal.Name, commodity: valueCommodity}
+		if acc, found := values[key]; found {
+			sum, err := acc.Balance.CheckedAdd(value)
+			if err != nil {
+				return nil, fmt.Errorf("balance of %s: %w", bal.Name, err)
+			}
+			acc.Balance = sum
+		} else {
+			acc = &Account{Name: bal.Name, Balance: value, Commodity: valueCommodity}
+			results = append(results, acc)
+			values[key] = acc
+		}
+	}
+
+	slices.SortFunc(results, func(a, b *Account) int {
+		return cmp.Or(
+			strings.Compare(a.Name, b.Name),
+			strings.Compare(a.Commodity, b.Commodity),
+		)
+	})
+	return results, nil
+}
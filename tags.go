@@ -0,0 +1,61 @@
+package ledger
+
+import (
+	"strings"
+)
+
+// parseTags adds the metadata in a comment to tags, returning tags. A comment
+// holds either a list of tags (; :tag1:tag2:), which have empty values, or a
+// single key and value (; Key: value) where the key is a single word followed
+// by a colon and a space. Returns tags unchanged (possibly nil)
+// when the comment holds no metadata.
+func parseTags(comment string, tags map[string]string) map[string]string {
+	if strings.IndexByte(comment, ':') < 0 {
+		return tags
+	}
+	comment = strings.TrimSpace(strings.TrimLeft(comment, ";"))
+
+	// Key: value
+	if key, value, found := strings.Cut(comment, ":"); found && len(key) > 0 && !strings.ContainsFunc(key, isTagSeparator) &&
+		(len(value) == 0 || isTagSeparator(rune(value[0]))) {
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[key] = strings.TrimSpace(value)
+		return tags
+	}
+
+	// :tag1:tag2:
+	for field := range strings.FieldsSeq(comment) {
+		if len(field) < 3 || field[0] != ':' || field[len(field)-1] != ':' {
+			continue
+		}
+		for tag := range strings.SplitSeq(field[1:len(field)-1], ":") {
+			if len(tag) == 0 {
+				continue
+			}
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[tag] = ""
+		}
+	}
+	return tags
+}
+
+func isTagSeparator(r rune) bool {
+	return r == ':' || r == ' ' || r == '\t'
+}
+
+// PostingHasTag returns true if the posting has the tag. The tag is either a
+// name, which matches any value, or name=value, which only matches that
+// value. Postings have the tags of their transaction, unless the posting has
+// its own value for the tag.
+func (t *Transaction) PostingHasTag(posting *Account, tag string) bool {
+	name, value, hasValue := strings.Cut(tag, "=")
+	tagValue, found := posting.Tags[name]
+	if !found {
+		tagValue, found = t.Tags[name]
+	}
+	return found && (!hasValue || tagValue == value)
+}
@@ -1,6 +1,7 @@
 package ledger
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/howeyc/ledger/decimal"
@@ -8,9 +9,81 @@ import (
 
 // Account holds the name and balance
 type Account struct {
-	Name    string
+	Name string
+	// Kind is only set for virtual postings.
+	Kind PostingKind
+	// Status is only set for postings marked with their own status.
+	Status  Status
 	Balance decimal.Decimal
-	Comment string
+	// Commodity is the symbol the balance is denominated in, and is empty
+	// for plain amounts.
+	Commodity string
+	// Cost is only set for postings with a price or lot cost annotation.
+	Cost *Cost
+	// Assertion is only set for postings with a balance assertion.
+	Assertion *Assertion
+	Comment   string
+	// Tags is the metadata in Comment.
+	Tags map[string]string
+	// EffectiveDate is only set for postings with an effective date
+	// ([=DATE]) in Comment.
+	EffectiveDate time.Time
+	// Line is only set for parsed postings, and is in the file of the
+	// Position of the transaction.
+	Line int
+}
+
+// Position is where a transaction is in a ledger file, the lines from Line to
+// EndLine (inclusive, starting at 1).
+type Position struct {
+	Filename      string
+	Line, EndLine int
+}
+
+// String returns the position as FILE:LINE, or FILE:LINE-ENDLINE for more
+// than one line.
+func (p Position) String() string {
+	if p.EndLine > p.Line {
+		return fmt.Sprintf("%s:%d-%d", p.Filename, p.Line, p.EndLine)
+	}
+	return fmt.Sprintf("%s:%d", p.Filename, p.Line)
+}
+
+// PostingKind is the kind of account a posting is to.
+type PostingKind int
+
+// Postings are to real accounts unless the account is in parentheses, a
+// virtual posting that does not need to balance, or in brackets, a virtual
+// posting that must balance with the other bracketed postings.
+const (
+	RealPosting PostingKind = iota
+	VirtualPosting
+	BalancedVirtualPosting
+)
+
+// Assertion is the balance (= AMOUNT) of a commodity in an account asserted
+// after a posting.
+type Assertion struct {
+	Balance   decimal.Decimal
+	Commodity string
+}
+
+// Cost holds the price (@, @@) and lot cost ({}) annotations of a posting.
+//
+// Transactions balance using Total in Commodity in place of the posting
+// amount. Without a price annotation, the price is the lot cost.
+type Cost struct {
+	Price     decimal.Decimal
+	Total     decimal.Decimal
+	Commodity string
+
+	LotPrice     decimal.Decimal
+	LotCommodity string
+}
+
+// HasLot returns true if the cost has a lot cost ({}) annotation.
+func (c *Cost) HasLot() bool {
+	return len(c.LotCommodity) > 0 || !c.LotPrice.IsZero()
 }
 
 // Transaction is the basis of a ledger. The ledger holds a list of transactions.
@@ -18,9 +91,101 @@ type Account struct {
 // hours,minutes,seconds values that probably doesn't make sense), and a list of
 // Account values that hold the value of the transaction for each account.
 type Transaction struct {
-	Date           time.Time
+	Date time.Time
+	// EffectiveDate is only set for transactions with an effective
+	// (auxiliary) date after the Date (DATE=EFFECTIVE).
+	EffectiveDate time.Time
+	// Status and Code are the optional status mark and (CODE) before the
+	// Payee.
+	Status         Status
+	Code           string
 	Payee          string
 	PayeeComment   string
 	AccountChanges []Account
 	Comments       []string
+	// Tags is the metadata in PayeeComment and Comments.
+	Tags map[string]string
+	// Position is from the Payee line to the last posting.
+	Position Position
+}
+
+// Status is the clearing status of a transaction or posting.
+type Status int
+
+// Transactions and postings are uncleared unless marked pending (!) or
+// cleared (*).
+const (
+	Uncleared Status = iota
+	Pending
+	Cleared
+)
+
+// PostingStatus returns the status of the posting, which is the status of the
+// transaction unless the posting is marked with a later status.
+func (t *Transaction) PostingStatus(posting *Account) Status {
+	return max(t.Status, posting.Status)
+}
+
+// PostingEffectiveDate returns the effective date of the posting, which is
+// the effective date of the posting, or else of the transaction, or else the
+// date of the transaction.
+func (t *Transaction) PostingEffectiveDate(posting *Account) time.Time {
+	switch {
+	case !posting.EffectiveDate.IsZero():
+		return posting.EffectiveDate
+	case !t.EffectiveDate.IsZero():
+		return t.EffectiveDate
+	}
+	return t.Date
+}
+
+// PeriodicTransaction is a transaction that repeats every Period, on the
+// first day of each period, from Start until End. A zero Start or End is
+// unbounded. The Transaction has no Date.
+type PeriodicTransaction struct {
+	Period     Period
+	Start, End time.Time
+	Transaction
+}
+
+// Price is the price of one unit of Commodity, in PriceCommodity, on Date.
+type Price struct {
+	Date           time.Time
+	Commodity      string
+	Amount         decimal.Decimal
+	PriceCommodity string
+}
+
+// AccountDeclaration is an account declared with an account directive, along
+// with the values of its sub-directives.
+type AccountDeclaration struct {
+	Name     string
+	Note     string
+	Aliases  []string
+	Default  bool
+	Asserts  []string
+	Checks   []string
+	Comments []string
+}
+
+// CommodityDeclaration is a commodity declared with a commodity directive,
+// along with the values of its sub-directives.
+type CommodityDeclaration struct {
+	Name     string
+	Note     string
+	Format   string
+	Aliases  []string
+	Default  bool
+	Comments []string
+}
+
+// Journal holds the contents of a ledger file. Along with the transactions,
+// it holds what was declared by directives in the file.
+type Journal struct {
+	Transactions []*Transaction
+	Prices       PriceHistory
+	Accounts     []*AccountDeclaration
+	Commodities  []*CommodityDeclaration
+	Periodic     []*PeriodicTransaction
+	Automated    []*AutomatedTransaction
 }